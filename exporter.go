@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/config"
+	"github.com/j4ng5y/dohdig/pkg/exporter"
+	"github.com/spf13/cobra"
+)
+
+// newExporterCmd creates the exporter command, which runs the probes of the config file that
+// cfg points at once it has been loaded
+func newExporterCmd(cfg **config.Config) *cobra.Command {
+	var (
+		listenFlag   string
+		intervalFlag time.Duration
+		cmd          = &cobra.Command{
+			Use:     "exporter",
+			Short:   "run the probes from the config file and expose Prometheus metrics",
+			Example: "dohdig exporter --listen :9553 --interval 30s",
+			Args:    cobra.NoArgs,
+			// Serving errors are not usage errors, so they are reported without the usage
+			SilenceUsage: true,
+			RunE: func(ccmd *cobra.Command, args []string) error {
+				if intervalFlag <= 0 {
+					return fmt.Errorf("the --interval flag must be greater than zero")
+				}
+
+				var probes []exporter.Probe
+				if *cfg != nil {
+					for _, p := range (*cfg).Probes {
+						probes = append(probes, exporter.Probe{
+							Name:     p.Name,
+							Type:     p.Type,
+							Provider: p.Provider,
+							Expect:   p.Expect,
+						})
+					}
+				}
+				if len(probes) == 0 {
+					log.Print("no probes are configured, only /probe will return results")
+				}
+
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer stop()
+
+				e := exporter.New(probes, intervalFlag)
+				go e.Start(ctx)
+
+				srv := &http.Server{Addr: listenFlag, Handler: e.Handler()}
+				go func() {
+					<-ctx.Done()
+					srv.Shutdown(context.Background())
+				}()
+
+				log.Printf("serving /metrics and /probe on %s", listenFlag)
+				if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					return fmt.Errorf("error serving /metrics and /probe on %s, err: %w", listenFlag, err)
+				}
+				return nil
+			},
+		}
+	)
+
+	cmd.Flags().StringVar(&listenFlag, "listen", ":9553", "The address to serve metrics on")
+	cmd.Flags().DurationVar(&intervalFlag, "interval", 30*time.Second, "How often the configured probes are run")
+	return cmd
+}
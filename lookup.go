@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+// maxLookups bounds the number of queries that are in flight at once when several names or
+// types are looked up together
+const maxLookups = 8
+
+// lookupResult is the response to one name and type of a run that looks up several
+type lookupResult struct {
+	Name     string                `json:"name"`
+	Type     string                `json:"type"`
+	Response *common.QueryResponse `json:"response,omitempty"`
+	Error    string                `json:"error,omitempty"`
+
+	// RawResponse is the HTTP response of a lookup that failed because it could not be decoded
+	RawResponse *common.ResponseInfo `json:"raw_response,omitempty"`
+}
+
+// splitTypes splits a comma separated list of record types, dropping repeated ones and rejecting
+// unknown ones before anything is queried
+func splitTypes(s string) ([]string, error) {
+	var (
+		types []string
+		seen  = make(map[int]bool)
+	)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		t, err := common.TypeFromName(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		types = append(types, strings.ToUpper(name))
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("the --record-type flag requires at least one record type")
+	}
+	return types, nil
+}
+
+// lookupAll queries every name for every type in parallel, returning the results grouped by name
+// in the order the names were given and then by type in the order the types were given. When
+// each is set it is called with every response before lookupAll returns, and the error it
+// returns is recorded on the result alongside the response
+func lookupAll(p provider.Provider, base provider.Query, names, types []string, each func(*common.QueryResponse, provider.Query) error) []lookupResult {
+	results := make([]lookupResult, len(names)*len(types))
+	sem := make(chan struct{}, maxLookups)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		for j, t := range types {
+			wg.Add(1)
+			go func(n int, name, t string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				q := base
+				q.Resource, q.ResourceType = name, t
+				r := lookupResult{Name: name, Type: t}
+				resp, err := p.New(q).Do()
+				if err != nil {
+					r.Error = err.Error()
+					var re *common.ResponseError
+					if errors.As(err, &re) {
+						r.RawResponse = re.Response
+					}
+					results[n] = r
+					return
+				}
+				r.Response = resp
+				if each != nil {
+					if err := each(resp, q); err != nil {
+						r.Error = err.Error()
+					}
+				}
+				results[n] = r
+			}(i*len(types)+j, name, t)
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// printLookups prints the results of lookupAll in the selected output format, as a single JSON
+// array or as one section per name and type, returning the number of lookups that failed
+func printLookups(results []lookupResult, opts printOptions) (int, error) {
+	failed := 0
+	for i, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+		if r.Response != nil {
+			trimResponse(r.Response, opts)
+		}
+		if !opts.raw {
+			results[i].RawResponse = nil
+		}
+	}
+
+	if opts.format == "json" {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return failed, fmt.Errorf("error marshalling results, err: %w", err)
+		}
+		fmt.Println(string(b))
+		return failed, nil
+	}
+
+	for i, r := range results {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Querying: %s %s\n", displayName(r.Name), r.Type)
+		if r.Response != nil {
+			printText(r.Response, opts)
+		}
+		if r.Error != "" {
+			fmt.Printf("Error: %s\n", r.Error)
+		}
+		if r.RawResponse != nil {
+			r.RawResponse.Print()
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+func TestSplitTypes(t *testing.T) {
+	got, err := splitTypes("a, AAAA,mx,,A,type1,txt")
+	if err != nil {
+		t.Fatalf("splitTypes() error = %v", err)
+	}
+	if want := []string{"A", "AAAA", "MX", "TXT"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("splitTypes() = %v, want %v", got, want)
+	}
+
+	for _, s := range []string{"A,BOGUS", ",", ""} {
+		if _, err := splitTypes(s); err == nil {
+			t.Errorf("splitTypes(%q) error = nil", s)
+		}
+	}
+}
+
+func TestLookupAll(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "192.0.2.1"}}})
+	s.Handle("example.com", 15, dohtest.Answer{Records: []dohtest.Record{{Name: "example.com.", Type: 15, TTL: 300, Data: "10 mail.example.com."}}})
+	s.Handle("example.org", 1, dohtest.Answer{Records: []dohtest.Record{{Name: "example.org.", Type: 1, TTL: 300, Data: "192.0.2.2"}}})
+
+	p, err := provider.Get("cloudflare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu   sync.Mutex
+		each []string
+	)
+	results := lookupAll(p, provider.Query{}, []string{"example.com", "example.org"}, []string{"A", "MX"}, func(_ *common.QueryResponse, q provider.Query) error {
+		mu.Lock()
+		defer mu.Unlock()
+		each = append(each, q.Resource+" "+q.ResourceType)
+		return nil
+	})
+	if len(s.Requests()) != 4 || len(each) != 4 {
+		t.Errorf("server received %d requests and each was called %d times, want 4", len(s.Requests()), len(each))
+	}
+
+	want := []struct {
+		name, t, status, data string
+	}{
+		{"example.com", "A", "NOERROR", "192.0.2.1"},
+		{"example.com", "MX", "NOERROR", "10 mail.example.com."},
+		{"example.org", "A", "NOERROR", "192.0.2.2"},
+		{"example.org", "MX", "NXDOMAIN", ""},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("lookupAll() returned %d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Name != w.name || r.Type != w.t || r.Error != "" {
+			t.Errorf("result %d = %s %s (%s), want %s %s", i, r.Name, r.Type, r.Error, w.name, w.t)
+			continue
+		}
+		if r.Response.StatusName != w.status {
+			t.Errorf("result %d status = %s, want %s", i, r.Response.StatusName, w.status)
+		}
+		var data string
+		if len(r.Response.Answer) > 0 {
+			data = r.Response.Answer[0].Data
+		}
+		if data != w.data {
+			t.Errorf("result %d data = %q, want %q", i, data, w.data)
+		}
+	}
+}
+
+func TestLookupAllDetectBlocking(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "0.0.0.0"}}})
+
+	p, err := provider.Get("cloudflare")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A reference provider that does not exist fails every result without ending the run
+	results := lookupAll(p, provider.Query{}, []string{"example.com", "example.org"}, []string{"A"}, func(resp *common.QueryResponse, q provider.Query) error {
+		return detectBlocking(resp, q, "bogus", false)
+	})
+	for _, r := range results {
+		if r.Response == nil || r.Error == "" {
+			t.Errorf("%s: response = %v, error = %q, want both", r.Name, r.Response, r.Error)
+		}
+	}
+
+	results = lookupAll(p, provider.Query{}, []string{"example.com"}, []string{"A"}, func(resp *common.QueryResponse, q provider.Query) error {
+		return detectBlocking(resp, q, "google", false)
+	})
+	if r := results[0]; r.Error != "" || r.Response.Blocking == nil || r.Response.Blocking.Verdict != common.VerdictNotBlocked {
+		t.Errorf("result = %+v, want a NOT BLOCKED verdict against the same sinkhole answer", r)
+	}
+}
@@ -1,37 +1,26 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/j4ng5y/dohdig/pkg/blahdns"
-	"github.com/j4ng5y/dohdig/pkg/cloudflare"
+	"github.com/j4ng5y/dohdig/pkg/audit"
+	"github.com/j4ng5y/dohdig/pkg/check"
 	"github.com/j4ng5y/dohdig/pkg/common"
-	"github.com/j4ng5y/dohdig/pkg/google"
-	"github.com/j4ng5y/dohdig/pkg/nextdns"
-	"github.com/j4ng5y/dohdig/pkg/nixnet"
-	"github.com/j4ng5y/dohdig/pkg/securedns"
-	"github.com/j4ng5y/dohdig/pkg/snopyta"
+	"github.com/j4ng5y/dohdig/pkg/config"
+	"github.com/j4ng5y/dohdig/pkg/dnstap"
+	"github.com/j4ng5y/dohdig/pkg/provider"
 	"github.com/spf13/cobra"
 )
 
 func execute() {
 	var (
-		validProviders = []string{
-			"google",
-			"cloudflare",
-			"blahdns-fi",
-			"blahdns-jp",
-			"blahdns-de",
-			"nextdns",
-			"nixnet-uncensored",
-			"nixnet-adblock",
-			"nixnet-lasvegas",
-			"nixnet-newyork",
-			"nixnet-luxembourg",
-			"securedns",
-			"snopyta",
-		}
 		providerFlag         string
 		showOptionsFlag      bool
 		typeFlag             string
@@ -41,228 +30,196 @@ func execute() {
 		eDNSClientSubnetFlag string
 		randomPaddingFlag    string
 		nextDNSID            string
+		nextDNSDeviceName    string
+		nextDNSDeviceModel   string
+		formatFlag           string
+		configFlag           string
+		profileFlag          string
+		bootstrapFlag        []string
+		pinFlag              []string
+		tlsInfoFlag          bool
+		wireFlag             bool
+		paddingFlag          string
+		verboseFlag          bool
+		showQueryFlag        bool
+		rawFlag              bool
+		watchFlag            time.Duration
+		untilFlag            string
+		expectRCodeFlag      string
+		expectAnswerFlag     string
+		expectADFlag         bool
+		maxLatencyFlag       time.Duration
+		minTTLFlag           int
+		detectBlockingFlag   bool
+		referenceFlag        string
+		recordFlag           string
+		replayFlag           string
+		auditLogFlag         string
+		auditMaxSizeFlag     int64
+		auditBackupsFlag     int
+		dnstapFlag           string
+		closers              []io.Closer
+		unobserve            []func()
+		cfg                  *config.Config
 		dohdigCmd            = &cobra.Command{
-			Use:     "dohdig",
+			Use:     "dohdig NAME...",
 			Short:   "A small, dig-like command that only runs against the dns.google.com API",
-			Example: "dohdig www.google.com",
+			Example: "dohdig www.google.com\n  dohdig example.com example.org -t A,AAAA,MX,TXT",
 			Version: "0.2.3",
-			Args:    cobra.ExactArgs(1),
+			Args:    cobra.MinimumNArgs(1),
 			Run: func(ccmd *cobra.Command, args []string) {
-				var err error
-				resp := new(common.QueryResponse)
-
-				fmt.Printf("Querying: %s\n", args[0])
-				if showOptionsFlag {
-					fmt.Printf(
-						optsStr,
-						typeFlag,
-						ctFlag,
-						eDNSClientSubnetFlag,
-						randomPaddingFlag,
-						cdFlag,
-						doFlag)
+				if formatFlag != "text" && formatFlag != "json" {
+					log.Fatalf("%s is an unsupported output format", formatFlag)
 				}
 
-				switch providerFlag {
-				case "google":
-					req := google.QueryRequest{
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						ContentType:             ctFlag,
-						EDNSClientSubnet:        eDNSClientSubnetFlag,
-						RandomPadding:           randomPaddingFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
-					}
-
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
-					}
-
-					resp.Print()
-				case "cloudflare":
-					req := cloudflare.QueryRequest{
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
-					}
-
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
-					}
-
-					resp.Print()
-				case "blahdns-fi":
-					req := blahdns.QueryRequest{
-						Country:                 "fi",
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
-					}
-
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
+				// Any assertion turns the run into a monitoring plugin style check, which prints a
+				// single status line and exits with the status code
+				assertions := check.Assertions{
+					RCode:      expectRCodeFlag,
+					Answer:     expectAnswerFlag,
+					MaxLatency: maxLatencyFlag,
+					MinTTL:     minTTLFlag,
+				}
+				if ccmd.Flags().Changed("expect-ad") {
+					assertions.AD = &expectADFlag
+				}
+				checking := assertions != (check.Assertions{})
+				fatal := log.Fatal
+				if checking {
+					fatal = func(v ...interface{}) {
+						fmt.Println(check.Invalid(fmt.Errorf("%s", fmt.Sprint(v...))))
+						os.Exit(int(check.StatusUnknown))
 					}
-
-					resp.Print()
-				case "blahdns-jp":
-					req := blahdns.QueryRequest{
-						Country:                 "jp",
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
+					if err := assertions.Validate(); err != nil {
+						fatal(err)
 					}
-
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
-					}
-
-					resp.Print()
-				case "blahdns-de":
-					req := blahdns.QueryRequest{
-						Country:                 "de",
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
+					if watchFlag > 0 {
+						fatal("the --watch flag cannot be combined with assertions")
 					}
+				}
 
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
-					}
+				// Several names or types are looked up concurrently and printed as one group each
+				types, err := splitTypes(typeFlag)
+				if err != nil {
+					fatal(err)
+				}
+				multi := len(args) > 1 || len(types) > 1
+				if multi && (checking || watchFlag > 0) {
+					fatal("the --watch flag and assertions cannot be combined with several names or record types")
+				}
 
-					resp.Print()
-				case "nextdns":
-					if nextDNSID == "" {
-						log.Fatal("the --nextdns-id flag must be set to use NextDNS")
+				if formatFlag == "text" && !checking {
+					if !multi {
+						fmt.Printf("Querying: %s\n", displayName(args[0]))
 					}
-					req := nextdns.QueryRequest{
-						ID:                      nextDNSID,
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
+					if showOptionsFlag {
+						fmt.Printf(
+							optsStr,
+							typeFlag,
+							ctFlag,
+							eDNSClientSubnetFlag,
+							randomPaddingFlag,
+							paddingFlag,
+							cdFlag,
+							doFlag)
 					}
+				}
 
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
-					}
+				if providerFlag == "nextdns" && nextDNSID == "" {
+					fatal("the --nextdns-id flag must be set to use NextDNS")
+				}
 
-					resp.Print()
-				case "nixnet-uncensored":
-					req := nixnet.QueryRequest{
-						ServerType:              "uncensored",
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
-					}
+				p, err := provider.Get(providerFlag)
+				if err != nil {
+					fatal(err)
+				}
 
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
+				for _, host := range p.Hosts() {
+					if len(bootstrapFlag) > 0 {
+						if err := common.SetBootstrap(host, bootstrapFlag); err != nil {
+							fatal(err)
+						}
 					}
-
-					resp.Print()
-				case "nixnet-adblock":
-					req := nixnet.QueryRequest{
-						ServerType:              "adblock",
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
+					if len(pinFlag) > 0 {
+						if err := common.SetPins(host, pinFlag); err != nil {
+							fatal(err)
+						}
 					}
+				}
 
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
-					}
+				// Only Google's JSON API takes a client subnet, so fall back to wire-format
+				// queries, which carry it as an EDNS0 option, for every other provider
+				wire := wireFlag || (ccmd.Flags().Changed("edns-client-subnet") && !p.JSONClientSubnet)
+
+				query := provider.Query{
+					Resource:                args[0],
+					ResourceType:            types[0],
+					ContentType:             ctFlag,
+					EDNSClientSubnet:        eDNSClientSubnetFlag,
+					RandomPadding:           randomPaddingFlag,
+					Padding:                 paddingFlag,
+					DisableDNSSECValidation: cdFlag,
+					ShowDNSSEC:              doFlag,
+					NextDNSID:               nextDNSID,
+					NextDNSDeviceName:       nextDNSDeviceName,
+					NextDNSDeviceModel:      nextDNSDeviceModel,
+					Wire:                    wire,
+				}
+				opts := printOptions{
+					format:    formatFlag,
+					verbose:   verboseFlag,
+					tlsInfo:   tlsInfoFlag,
+					showQuery: showQueryFlag,
+					raw:       rawFlag,
+				}
 
-					resp.Print()
-				case "nixnet-lasvegas":
-					req := nixnet.QueryRequest{
-						ServerType:              "lasvegas",
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
+				if multi {
+					var each func(*common.QueryResponse, provider.Query) error
+					if detectBlockingFlag {
+						subnet := ccmd.Flags().Changed("edns-client-subnet")
+						each = func(resp *common.QueryResponse, q provider.Query) error {
+							return detectBlocking(resp, q, referenceFlag, subnet)
+						}
 					}
-
-					resp, err = req.Do()
+					failed, err := printLookups(lookupAll(p, query, args, types, each), opts)
 					if err != nil {
 						log.Fatal(err)
 					}
-
-					resp.Print()
-				case "nixnet-newyork":
-					req := nixnet.QueryRequest{
-						ServerType:              "newyork",
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
-					}
-
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
+					if failed > 0 {
+						log.Fatalf("%d of %d queries failed", failed, len(args)*len(types))
 					}
+					return
+				}
 
-					resp.Print()
-				case "nixnet-luxembourg":
-					req := nixnet.QueryRequest{
-						ServerType:              "luxembourg",
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
-					}
+				req := p.New(query)
 
-					resp, err = req.Do()
-					if err != nil {
+				if watchFlag > 0 {
+					if err := watch(req, watchFlag, untilFlag, formatFlag); err != nil {
 						log.Fatal(err)
 					}
+					return
+				}
 
-					resp.Print()
-				case "securedns":
-					req := securedns.QueryRequest{
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
-					}
-
-					resp, err = req.Do()
-					if err != nil {
-						log.Fatal(err)
-					}
+				if checking {
+					os.Exit(int(runCheck(req, assertions)))
+				}
 
-					resp.Print()
-				case "snopyta":
-					req := snopyta.QueryRequest{
-						Resource:                args[0],
-						ResourceType:            typeFlag,
-						DisableDNSSECValidation: cdFlag,
-						ShowDNSSEC:              doFlag,
+				resp, err := req.Do()
+				if err != nil {
+					if rawFlag {
+						printFailedResponse(err)
 					}
+					log.Fatal(err)
+				}
 
-					resp, err = req.Do()
-					if err != nil {
+				if detectBlockingFlag {
+					if err := detectBlocking(resp, query, referenceFlag, ccmd.Flags().Changed("edns-client-subnet")); err != nil {
 						log.Fatal(err)
 					}
+				}
 
-					resp.Print()
-				default:
-					log.Fatalf("%s is an unsuppored provider", providerFlag)
+				if err := printResponse(resp, opts); err != nil {
+					log.Fatal(err)
 				}
 			},
 		}
@@ -272,28 +229,270 @@ func execute() {
 			Short: "list available providers",
 			Run: func(ccmd *cobra.Command, args []string) {
 				fmt.Println("Valid Providers:")
-				for _, v := range validProviders {
+				for _, v := range provider.Names() {
 					fmt.Printf("  %s\n", v)
 				}
 			},
 		}
 	)
 
-	dohdigCmd.AddCommand(listCmd)
+	dohdigCmd.PersistentPreRunE = func(ccmd *cobra.Command, args []string) error {
+		c, err := applyConfig(dohdigCmd, ccmd, configFlag, profileFlag)
+		cfg = c
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case recordFlag != "" && replayFlag != "":
+			return fmt.Errorf("the --record and --replay flags cannot be combined")
+		case recordFlag != "":
+			err = common.Record(recordFlag)
+		case replayFlag != "":
+			err = common.Replay(replayFlag)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Every lookup made through the provider registry is logged, whichever command makes it
+		if auditLogFlag != "" {
+			l, err := audit.Open(auditLogFlag, auditMaxSizeFlag<<20, auditBackupsFlag)
+			if err != nil {
+				return err
+			}
+			unobserve = append(unobserve, provider.Observe(l.Observe))
+			closers = append(closers, l)
+		}
+		if dnstapFlag != "" {
+			w, err := dnstap.Open(dnstapFlag)
+			if err != nil {
+				return err
+			}
+			unobserve = append(unobserve, provider.Observe(w.Observe))
+			closers = append(closers, w)
+		}
+		return nil
+	}
+	dohdigCmd.AddCommand(listCmd, newPropagationCmd(), newReportCmd(), newExporterCmd(&cfg), newShellCmd())
+	dohdigCmd.PersistentFlags().StringVar(&configFlag, "config", "", "The config file to read (default is $XDG_CONFIG_HOME/dohdig/config.yaml)")
+	dohdigCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "The config file profile to apply")
 	dohdigCmd.Flags().StringVarP(&providerFlag, "provider", "i", "google", "The provider to use")
-	dohdigCmd.Flags().StringVarP(&typeFlag, "record-type", "t", "A", "The DNS record type to query")
+	dohdigCmd.Flags().StringVarP(&typeFlag, "record-type", "t", "A", "The DNS record types to query, separated by commas")
 	dohdigCmd.Flags().StringVarP(&ctFlag, "content-type", "c", "application/x-javascript", "The desired content type to return")
-	dohdigCmd.Flags().StringVarP(&eDNSClientSubnetFlag, "edns-client-subnet", "e", "0.0.0.0/0", "Set source IP address for DNS resolution")
-	dohdigCmd.Flags().StringVarP(&randomPaddingFlag, "random-padding", "p", "", "Pad request with random data")
+	dohdigCmd.Flags().StringVarP(&eDNSClientSubnetFlag, "edns-client-subnet", "e", "0.0.0.0/0", "Set source IP address for DNS resolution, providers other than google send it over --wire")
+	dohdigCmd.Flags().StringVarP(&randomPaddingFlag, "random-padding", "p", "", "Pad Google JSON requests with this value instead of generated padding")
+	dohdigCmd.Flags().StringVar(&paddingFlag, "padding", common.PaddingBlock, "The padding policy, one of: none, block (RFC 8467 128 octet blocks), random")
+	dohdigCmd.Flags().BoolVarP(&verboseFlag, "verbose", "v", false, "Show the request that was sent, including its padded size")
+	dohdigCmd.Flags().BoolVar(&showQueryFlag, "show-query", false, "Show the request exactly as it was sent, with its headers and a dump of wire-format queries")
+	dohdigCmd.Flags().BoolVar(&rawFlag, "raw", false, "Show the HTTP response headers and the raw body, with a dump of wire-format responses")
 	dohdigCmd.Flags().BoolVarP(&cdFlag, "disable-dnssec-checking", "n", false, "Disable DNS validation")
 	dohdigCmd.Flags().BoolVarP(&doFlag, "show-dnssec", "d", true, "Show DNSSEC information in response")
 	dohdigCmd.Flags().BoolVarP(&showOptionsFlag, "show-options", "o", false, "Show configured options in the output")
-
-	if err := dohdigCmd.Execute(); err != nil {
+	dohdigCmd.Flags().StringVarP(&formatFlag, "format", "f", "text", "The output format, one of: text, json")
+	dohdigCmd.Flags().StringVar(&nextDNSID, "nextdns-id", "", "The NextDNS profile ID, required by the nextdns provider")
+	dohdigCmd.Flags().StringVar(&nextDNSDeviceName, "nextdns-device-name", "", "The device name to report to NextDNS analytics")
+	dohdigCmd.Flags().StringVar(&nextDNSDeviceModel, "nextdns-device-model", "", "The device model to report to NextDNS analytics")
+	dohdigCmd.Flags().BoolVarP(&wireFlag, "wire", "w", false, "Send RFC 8484 wire-format queries instead of using the JSON API")
+	dohdigCmd.Flags().BoolVar(&tlsInfoFlag, "tls-info", false, "Show the TLS connection the response was received over")
+	dohdigCmd.Flags().DurationVar(&watchFlag, "watch", 0, "Re-run the query at this interval and highlight changes in the answer")
+	dohdigCmd.Flags().StringVar(&untilFlag, "until", "", "With --watch, exit once an answer with this data appears")
+	dohdigCmd.Flags().StringSliceVar(&bootstrapFlag, "bootstrap", nil, "The IP addresses used to connect to the provider instead of the system resolver")
+	dohdigCmd.Flags().StringSliceVar(&pinFlag, "pin", nil, "The SPKI SHA-256 pins (base64) the provider's certificate chain must match")
+	dohdigCmd.Flags().StringVar(&expectRCodeFlag, "expect-rcode", "", "Exit CRITICAL unless the response code matches, by name or number")
+	dohdigCmd.Flags().StringVar(&expectAnswerFlag, "expect-answer", "", "Exit CRITICAL unless an answer matches, prefix with contains: or regex: for partial matches")
+	dohdigCmd.Flags().BoolVar(&expectADFlag, "expect-ad", false, "Exit CRITICAL unless the AD (DNSSEC validated) bit matches")
+	dohdigCmd.Flags().DurationVar(&maxLatencyFlag, "max-latency", 0, "Exit WARNING when the query takes longer than this")
+	dohdigCmd.Flags().IntVar(&minTTLFlag, "min-ttl", 0, "Exit WARNING when an answer's TTL is below this")
+	dohdigCmd.Flags().BoolVar(&detectBlockingFlag, "detect-blocking", false, "Check whether the answer was filtered, comparing it to the --reference provider")
+	dohdigCmd.Flags().StringVar(&referenceFlag, "reference", "cloudflare", "The unfiltered provider --detect-blocking compares answers to")
+	dohdigCmd.PersistentFlags().StringVar(&recordFlag, "record", "", "Save the HTTP requests and responses of the run to this file")
+	dohdigCmd.PersistentFlags().StringVar(&replayFlag, "replay", "", "Answer requests with the responses saved by --record instead of the network")
+	dohdigCmd.PersistentFlags().StringVar(&auditLogFlag, "audit-log", "", "Append a JSON line describing every lookup to this file")
+	dohdigCmd.PersistentFlags().Int64Var(&auditMaxSizeFlag, "audit-log-max-size", audit.DefaultMaxSize>>20, "The size in megabytes past which the audit log is rotated, 0 to never rotate")
+	dohdigCmd.PersistentFlags().IntVar(&auditBackupsFlag, "audit-log-backups", audit.DefaultBackups, "The number of rotated audit logs to keep")
+	dohdigCmd.PersistentFlags().StringVar(&dnstapFlag, "dnstap", "", "Write dnstap CLIENT_QUERY and CLIENT_RESPONSE messages of every lookup to this file, or unix:PATH for a socket")
+	dohdigCmd.PersistentFlags().DurationVar(&common.Client.Timeout, "timeout", common.DefaultTimeout, "The timeout for each HTTP request")
+
+	// The closers are closed here rather than in a PersistentPostRun, which cobra skips when a
+	// command returns an error, once nothing observes lookups into them anymore
+	err := dohdigCmd.Execute()
+	for _, remove := range unobserve {
+		remove()
+	}
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+// applyConfig registers the custom providers from the config file and uses its
+// defaults and profile for any flag that was not set on the command line, returning
+// the config so that subcommands can read the rest of it
+func applyConfig(root, ccmd *cobra.Command, path, profile string) (*config.Config, error) {
+	explicit := path != ""
+	if !explicit {
+		p, err := config.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		if !explicit && os.IsNotExist(err) {
+			if profile != "" {
+				return nil, fmt.Errorf("the --profile flag requires a config file, %s does not exist", path)
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := cfg.Providers[name]
+		if err := provider.RegisterCustom(provider.Custom{
+			Name:        name,
+			Description: p.Description,
+			Location:    p.Location,
+			URL:         p.URL,
+			Bootstrap:   p.Bootstrap,
+			Pins:        p.Pins,
+			Wire:        p.Format == "wire",
+		}); err != nil {
+			return nil, fmt.Errorf("error registering provider %s from %s, err: %w", name, path, err)
+		}
+	}
+
+	opts, err := cfg.Options(profile)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range opts {
+		if root.Flags().Lookup(name) == nil && root.PersistentFlags().Lookup(name) == nil {
+			return nil, fmt.Errorf("%s in %s is not a dohdig flag", name, path)
+		}
+
+		f := ccmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		if err := ccmd.Flags().Set(name, value); err != nil {
+			return nil, fmt.Errorf("error applying %s from %s, err: %w", name, path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// printOptions selects the output format and the optional sections of printResponse
+type printOptions struct {
+	format    string
+	verbose   bool
+	tlsInfo   bool
+	showQuery bool
+	raw       bool
+}
+
+// printResponse prints a response in the selected output format
+func printResponse(resp *common.QueryResponse, opts printOptions) error {
+	trimResponse(resp, opts)
+	if opts.format == "json" {
+		return resp.PrintJSON()
+	}
+	printText(resp, opts)
+	return nil
+}
+
+// printFailedResponse prints the HTTP response that a query could not be decoded from, when err
+// carries one
+func printFailedResponse(err error) {
+	var re *common.ResponseError
+	if errors.As(err, &re) {
+		re.Response.Print()
+	}
+}
+
+// trimResponse drops the parts of a response whose sections were not asked for, so that they
+// are left out of the JSON output as well
+func trimResponse(resp *common.QueryResponse, opts printOptions) {
+	if !opts.tlsInfo {
+		resp.TLS = nil
+	}
+	if !opts.raw {
+		resp.Response = nil
+	}
+	switch {
+	case !opts.verbose && !opts.showQuery:
+		resp.Request = nil
+	case !opts.showQuery:
+		resp.Request.Header = nil
+	}
+}
+
+// printText prints the sections of a trimmed response as text
+func printText(resp *common.QueryResponse, opts printOptions) {
+	if opts.showQuery {
+		resp.PrintQuery()
+	}
+	if opts.verbose {
+		resp.PrintRequest()
+	}
+	resp.Print()
+	resp.PrintProviderInfo()
+	resp.PrintBlocking()
+	resp.PrintTLSInfo()
+	resp.PrintRaw()
+}
+
+// runCheck runs the query and prints the outcome of the assertions as a monitoring plugin
+// status line, followed by perfdata
+func runCheck(req common.Do, assertions check.Assertions) check.Status {
+	resp, err := req.Do()
+	result := check.Failed(err)
+	if err == nil {
+		result = assertions.Evaluate(resp)
+	}
+	fmt.Println(result)
+	return result.Status
+}
+
+// detectBlocking queries the reference provider with the same query and assigns the blocking
+// verdict to resp, a failed reference query only weakens the verdict
+func detectBlocking(resp *common.QueryResponse, query provider.Query, reference string, subnet bool) error {
+	ref, err := provider.Get(reference)
+	if err != nil {
+		return fmt.Errorf("error detecting blocking, err: %w", err)
+	}
+
+	query.Wire = query.Wire || (subnet && !ref.JSONClientSubnet)
+	refResp, err := ref.New(query).Do()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error querying the reference provider %s, err: %v\n", reference, err)
+		refResp = nil
+	}
+	resp.DetectBlocking(reference, refResp)
+	return nil
+}
+
+// displayName returns name along with its ACE form when it is an internationalized domain name
+func displayName(name string) string {
+	ace, err := common.ToASCII(name)
+	if err != nil || strings.EqualFold(ace, name) {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, ace)
+}
+
 func main() {
 	execute()
 }
@@ -303,6 +502,7 @@ Record Type:        %s
 Content Type:       %s
 eDNS Client Subnet: %s
 Random Pad:         %s
+Padding Policy:     %s
 Disable DNSSEC:     %v
 Show DNSSEC:        %v
 `
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+	"github.com/spf13/cobra"
+)
+
+// newTestRoot returns a command with a few of the root command's flags for applyConfig to set
+func newTestRoot() *cobra.Command {
+	cmd := &cobra.Command{Use: "dohdig"}
+	cmd.Flags().StringP("provider", "i", "google", "")
+	cmd.Flags().StringP("record-type", "t", "A", "")
+	cmd.Flags().String("padding", "block", "")
+	cmd.Flags().Bool("wire", false, "")
+	cmd.PersistentFlags().Duration("timeout", 0, "")
+	return cmd
+}
+
+func TestApplyConfig(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "192.0.2.1"}}})
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := ioutil.WriteFile(path, []byte(`
+defaults:
+  provider: test-json
+  padding: none
+  record-type: AAAA
+profiles:
+  wire:
+    provider: test-wire
+    record-type: A
+providers:
+  test-json:
+    url: https://json.example.com/resolve
+  test-wire:
+    location: Lab
+    url: https://wire.example.com/dns-query
+    format: wire
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := newTestRoot()
+	if err := root.Flags().Set("padding", "random"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := applyConfig(root, root, path, "wire")
+	if err != nil {
+		t.Fatalf("applyConfig() error = %v", err)
+	}
+	if cfg == nil || len(cfg.Providers) != 2 {
+		t.Fatalf("applyConfig() config = %+v, want the two providers", cfg)
+	}
+
+	// The profile overrides the defaults, and neither overrides a flag set on the command line
+	for name, want := range map[string]string{"provider": "test-wire", "record-type": "A", "padding": "random"} {
+		if got := root.Flags().Lookup(name).Value.String(); got != want {
+			t.Errorf("--%s = %s, want %s", name, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		name, host, path string
+		wire             bool
+	}{
+		{"test-json", "json.example.com", "/resolve", false},
+		{"test-wire", "wire.example.com", "/dns-query", true},
+	} {
+		p, err := provider.Get(tt.name)
+		if err != nil {
+			t.Fatalf("provider %s was not registered, err: %v", tt.name, err)
+		}
+		resp, err := p.New(provider.Query{Resource: "example.com", ResourceType: "A"}).Do()
+		if err != nil {
+			t.Fatalf("%s: Do() error = %v", tt.name, err)
+		}
+		if len(resp.Answer) != 1 || resp.Answer[0].Data != "192.0.2.1" {
+			t.Errorf("%s: answer = %+v, want 192.0.2.1", tt.name, resp.Answer)
+		}
+
+		req := s.LastRequest()
+		if req.Host != tt.host || req.URL.Path != tt.path || (req.Message != nil) != tt.wire {
+			t.Errorf("%s: request sent to %s%s, wire-format %v, want %s%s, %v", tt.name, req.Host, req.URL.Path, req.Message != nil, tt.host, tt.path, tt.wire)
+		}
+	}
+	if p, _ := provider.Get("test-wire"); p.Location != "Lab" {
+		t.Errorf("test-wire location = %q, want Lab", p.Location)
+	}
+}
+
+func TestApplyConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	// Without a config file only an explicit path or profile is an error
+	if cfg, err := applyConfig(newTestRoot(), newTestRoot(), "", ""); cfg != nil || err != nil {
+		t.Errorf("applyConfig() without a file = %v, %v, want nil, nil", cfg, err)
+	}
+	if _, err := applyConfig(newTestRoot(), newTestRoot(), "", "office"); err == nil || !strings.Contains(err.Error(), "requires a config file") {
+		t.Errorf("applyConfig() of a profile without a file error = %v", err)
+	}
+	if _, err := applyConfig(newTestRoot(), newTestRoot(), filepath.Join(dir, "missing.yaml"), ""); err == nil {
+		t.Error("applyConfig() of a missing explicit file error = nil")
+	}
+
+	for _, tt := range []struct {
+		name, contents, profile, want string
+	}{
+		{"unknown flag", "defaults:\n  providr: cloudflare\n", "", "providr in"},
+		{"invalid flag value", "defaults:\n  wire: sometimes\n", "", "error applying wire"},
+		{"undefined profile", "defaults:\n  provider: cloudflare\n", "office", "profile office is not defined"},
+		{"invalid bootstrap", "providers:\n  broken:\n    url: https://doh.example.com/\n    bootstrap: [doh.example.com]\n", "", "error registering provider broken"},
+	} {
+		path := filepath.Join(dir, "config.yaml")
+		if err := ioutil.WriteFile(path, []byte(tt.contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := applyConfig(newTestRoot(), newTestRoot(), path, tt.profile)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: applyConfig() error = %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package audit writes a JSON lines log of every lookup, rotating the file once it grows past a
+// maximum size
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+// DefaultMaxSize is the size in bytes a log grows to before it is rotated
+const DefaultMaxSize = 100 << 20
+
+// DefaultBackups is the number of rotated logs that are kept
+const DefaultBackups = 5
+
+// Entry is a single line of the audit log
+type Entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Provider  string    `json:"provider"`
+	Endpoint  string    `json:"endpoint"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Flags     Flags     `json:"flags"`
+	RCode     int       `json:"rcode"`
+	RCodeName string    `json:"rcode_name,omitempty"`
+	Answers   []Answer  `json:"answers"`
+	LatencyMS float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Flags are the options the query was sent with
+type Flags struct {
+	Wire                    bool   `json:"wire"`
+	DisableDNSSECValidation bool   `json:"cd"`
+	ShowDNSSEC              bool   `json:"do"`
+	EDNSClientSubnet        string `json:"edns_client_subnet,omitempty"`
+	Padding                 string `json:"padding,omitempty"`
+}
+
+// Answer is an answer record of the response
+type Answer struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TTL  int    `json:"ttl"`
+	Data string `json:"data"`
+}
+
+// NewEntry builds the audit log entry of a lookup
+//
+// Arguments:
+//     e (pkg.provider.Event): The completed lookup
+//
+// Returns:
+//     (Entry): The entry, with an rcode of -1 when no response was received
+func NewEntry(e provider.Event) Entry {
+	entry := Entry{
+		Timestamp: e.Time.UTC(),
+		Provider:  e.Provider.Name,
+		Endpoint:  e.Endpoint(),
+		Name:      e.Query.Resource,
+		Type:      e.Query.ResourceType,
+		Flags: Flags{
+			Wire:                    e.Query.Wire,
+			DisableDNSSECValidation: e.Query.DisableDNSSECValidation,
+			ShowDNSSEC:              e.Query.ShowDNSSEC,
+			EDNSClientSubnet:        e.Query.EDNSClientSubnet,
+			Padding:                 e.Query.Padding,
+		},
+		RCode:     -1,
+		Answers:   []Answer{},
+		LatencyMS: float64(e.Latency) / float64(time.Millisecond),
+	}
+	if e.Err != nil {
+		entry.Error = e.Err.Error()
+	}
+
+	if e.Response != nil {
+		entry.RCode = e.Response.StatusCode
+		entry.RCodeName = e.Response.StatusName
+		for _, a := range e.Response.Answer {
+			a.DetermineTypeNameAndMeaning()
+			entry.Answers = append(entry.Answers, Answer{
+				Name: a.Name,
+				Type: a.TypeName,
+				TTL:  a.TTL,
+				Data: a.Data,
+			})
+		}
+	}
+	return entry
+}
+
+// Log is an append only JSON lines file, safe for concurrent use
+type Log struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens the log at path for appending, creating it if needed
+//
+// Arguments:
+//     path    (string): The file to log to
+//     maxSize (int64):  The size in bytes past which the file is rotated, 0 to never rotate
+//     backups (int):    The number of rotated files to keep as path.1, path.2 and so on
+//
+// Returns:
+//     (*Log):  A pointer to the open log, or nil if an error occurred
+//     (error): An error if one exists, nil otherwise
+func Open(path string, maxSize int64, backups int) (*Log, error) {
+	l := &Log{path: path, maxSize: maxSize, backups: backups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening the audit log, err: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error opening the audit log, err: %w", err)
+	}
+	l.f = f
+	l.size = info.Size()
+	return nil
+}
+
+// Write appends an entry to the log, rotating the file first if the entry would take it past
+// its maximum size
+//
+// Arguments:
+//     entry (Entry): The entry to append
+//
+// Returns:
+//     (error): An error if one exists, nil otherwise
+func (l *Log) Write(entry Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding the audit log entry, err: %w", err)
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return fmt.Errorf("the audit log is closed")
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing the audit log, err: %w", err)
+	}
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N down to path to path.1, dropping the oldest file, and starts
+// a new, empty log
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("error closing the audit log, err: %w", err)
+	}
+	l.f = nil
+
+	if l.backups <= 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error rotating the audit log, err: %w", err)
+		}
+		return l.open()
+	}
+
+	for i := l.backups - 1; i >= 1; i-- {
+		err := os.Rename(l.backup(i), l.backup(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error rotating the audit log, err: %w", err)
+		}
+	}
+	if err := os.Rename(l.path, l.backup(1)); err != nil {
+		return fmt.Errorf("error rotating the audit log, err: %w", err)
+	}
+	return l.open()
+}
+
+func (l *Log) backup(i int) string {
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// Observe writes the entry of a lookup to the log, reporting a failed write on stderr rather than
+// failing the lookup, so that it can be passed to pkg.provider.Observe
+//
+// Arguments:
+//     e (pkg.provider.Event): The completed lookup
+//
+// Returns:
+//     None
+func (l *Log) Observe(e provider.Event) {
+	if err := l.Write(NewEntry(e)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Close closes the log file
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (error): An error if one exists, nil otherwise
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+func TestObserve(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "93.184.216.34"}},
+	})
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer l.Close()
+	remove := provider.Observe(l.Observe)
+	defer remove()
+
+	p, err := provider.Get("cloudflare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.New(provider.Query{Resource: "example.com", ResourceType: "A", ShowDNSSEC: true}).Do(); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	entries := readEntries(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("%d entries logged, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Provider != "cloudflare" || e.Endpoint != "https://cloudflare-dns.com/dns-query" {
+		t.Errorf("provider = %s %s, want cloudflare https://cloudflare-dns.com/dns-query", e.Provider, e.Endpoint)
+	}
+	if e.Name != "example.com" || e.Type != "A" || !e.Flags.ShowDNSSEC {
+		t.Errorf("query = %s %s %+v, want example.com A with do set", e.Name, e.Type, e.Flags)
+	}
+	if e.RCode != 0 || e.RCodeName != "NOERROR" || e.Error != "" {
+		t.Errorf("outcome = %d %s %q, want NOERROR without an error", e.RCode, e.RCodeName, e.Error)
+	}
+	if len(e.Answers) != 1 || e.Answers[0] != (Answer{Name: "example.com.", Type: "A", TTL: 300, Data: "93.184.216.34"}) {
+		t.Errorf("answers = %+v, want the single A record", e.Answers)
+	}
+	if e.Timestamp.IsZero() || e.LatencyMS <= 0 {
+		t.Errorf("timestamp = %v, latency = %v, want both set", e.Timestamp, e.LatencyMS)
+	}
+
+	// Once removed, the log is no longer written to
+	remove()
+	if _, err := p.New(provider.Query{Resource: "example.com", ResourceType: "A"}).Do(); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if n := len(readEntries(t, path)); n != 1 {
+		t.Errorf("%d entries logged after removing the observer, want 1", n)
+	}
+}
+
+func TestNewEntryError(t *testing.T) {
+	e := NewEntry(provider.Event{
+		Time:     time.Now(),
+		Provider: provider.Provider{Name: "google", Endpoint: "https://dns.google.com/resolve", WireEndpoint: "https://dns.google/dns-query"},
+		Query:    provider.Query{Resource: "example.com", ResourceType: "AAAA", Wire: true},
+		Err:      errors.New("timeout"),
+	})
+
+	if e.Endpoint != "https://dns.google/dns-query" {
+		t.Errorf("endpoint = %s, want the wire-format endpoint", e.Endpoint)
+	}
+	if e.RCode != -1 || e.Error != "timeout" || e.Answers == nil {
+		t.Errorf("entry = %+v, want rcode -1, the error and an empty answer list", e)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	entry := Entry{Name: "example.com", Type: "A", Answers: []Answer{}}
+	b, _ := json.Marshal(entry)
+	lineSize := int64(len(b) + 1)
+
+	// Two entries fit in a file, so seven writes leave the log with one and three full backups
+	// of which only two are kept
+	l, err := Open(path, 2*lineSize, 2)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer l.Close()
+	for i := 0; i < 7; i++ {
+		if err := l.Write(entry); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	for file, want := range map[string]int{path: 1, path + ".1": 2, path + ".2": 2} {
+		if got := len(readEntries(t, file)); got != want {
+			t.Errorf("%s has %d entries, want %d", filepath.Base(file), got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists, want only 2 backups", filepath.Base(path))
+	}
+}
+
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []Entry
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("%s holds an invalid line %q, err: %v", path, sc.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
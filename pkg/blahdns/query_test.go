@@ -0,0 +1,49 @@
+package blahdns
+
+import (
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+)
+
+func TestDo(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "93.184.216.34"}},
+	})
+
+	for country, host := range map[string]string{
+		"fi": "doh-fi.blahdns.com",
+		"jp": "doh-jp.blahdns.com",
+		"de": "doh-de.blahdns.com",
+	} {
+		resp, err := QueryRequest{Country: country, Resource: "example.com", ResourceType: "A"}.Do()
+		if err != nil {
+			t.Fatalf("Do(%s) error = %v", country, err)
+		}
+
+		req := s.LastRequest()
+		if req.Host != host || req.URL.Path != "/dns-query" {
+			t.Errorf("Do(%s) sent to %s%s, want %s/dns-query", country, req.Host, req.URL.Path, host)
+		}
+		if got := req.Header.Get("Accept"); got != "application/dns-json" {
+			t.Errorf("Do(%s) Accept = %q, want application/dns-json", country, got)
+		}
+		if len(resp.Answer) != 1 || resp.Answer[0].Data != "93.184.216.34" {
+			t.Errorf("Do(%s) answer = %+v, want a single 93.184.216.34 record", country, resp.Answer)
+		}
+	}
+}
+
+func TestDoUnsupportedCountry(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+
+	if _, err := (QueryRequest{Country: "us", Resource: "example.com", ResourceType: "A"}).Do(); err == nil {
+		t.Error("Do() error = nil, want an unsupported country error")
+	}
+	if n := len(s.Requests()); n != 0 {
+		t.Errorf("%d requests sent, want 0", n)
+	}
+}
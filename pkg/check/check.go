@@ -0,0 +1,231 @@
+package check
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+)
+
+// Status is the exit status of a monitoring plugin, as used by Nagios and compatible systems
+type Status int
+
+// The monitoring plugin exit statuses
+const (
+	StatusOK Status = iota
+	StatusWarning
+	StatusCritical
+	StatusUnknown
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "OK"
+	case StatusWarning:
+		return "WARNING"
+	case StatusCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Assertions are the conditions a response must meet, the zero value of each field disables it
+type Assertions struct {
+	// RCode is the expected response code, by name or number
+	RCode string
+	// Answer is the expected answer data, optionally prefixed with exact:, contains: or regex:
+	Answer string
+	// AD is the expected state of the AD (DNSSEC validated) bit
+	AD *bool
+	// MaxLatency is the query time above which the check warns
+	MaxLatency time.Duration
+	// MinTTL is the answer TTL below which the check warns
+	MinTTL int
+}
+
+// Result is the outcome of evaluating the assertions against a response
+type Result struct {
+	Status   Status
+	Summary  string
+	Failures []string
+	Perfdata []string
+}
+
+// String formats the result as the single line a monitoring plugin prints
+func (r Result) String() string {
+	summary := r.Summary
+	if len(r.Failures) > 0 {
+		summary = strings.Join(r.Failures, "; ")
+	}
+
+	line := fmt.Sprintf("DNS %s - %s", r.Status, summary)
+	if len(r.Perfdata) > 0 {
+		line += " | " + strings.Join(r.Perfdata, " ")
+	}
+	return line
+}
+
+// Failed returns the result of a query that could not be completed
+//
+// Arguments:
+//     err (error): The reason the query failed
+//
+// Returns:
+//     (Result): A critical result
+func Failed(err error) Result {
+	return Result{Status: StatusCritical, Summary: err.Error()}
+}
+
+// Invalid returns the result of a check that could not be run as configured
+//
+// Arguments:
+//     err (error): The reason the check could not be run
+//
+// Returns:
+//     (Result): An unknown result
+func Invalid(err error) Result {
+	return Result{Status: StatusUnknown, Summary: err.Error()}
+}
+
+// Validate checks that the response code and answer assertions can be evaluated
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (error): An error if an assertion is invalid, nil otherwise
+func (a Assertions) Validate() error {
+	if a.RCode != "" {
+		if _, err := common.RCodeFromName(a.RCode); err != nil {
+			return err
+		}
+	}
+	if a.Answer != "" {
+		if _, err := answerMatcher(a.Answer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluate checks the response against the assertions, wrong data is critical while a slow
+// response or a short TTL is a warning
+//
+// Arguments:
+//     resp (*pkg.common.QueryResponse): The response to check
+//
+// Returns:
+//     (Result): The outcome of the check
+func (a Assertions) Evaluate(resp *common.QueryResponse) Result {
+	r := Result{Status: StatusOK}
+	fail := func(s Status, format string, v ...interface{}) {
+		if s > r.Status {
+			r.Status = s
+		}
+		r.Failures = append(r.Failures, fmt.Sprintf(format, v...))
+	}
+
+	if err := a.Validate(); err != nil {
+		return Invalid(err)
+	}
+
+	if a.RCode != "" {
+		code, _ := common.RCodeFromName(a.RCode)
+		if resp.StatusCode != code {
+			expected := common.QueryResponse{StatusCode: code}
+			expected.DetermineStatusMessage()
+			fail(StatusCritical, "rcode %s, expected %s", resp.StatusName, expected.StatusName)
+		}
+	}
+
+	if a.Answer != "" {
+		match, _ := answerMatcher(a.Answer)
+		found := false
+		for _, ans := range resp.Answer {
+			if match(ans.Data) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail(StatusCritical, "no answer matches %s", a.Answer)
+		}
+	}
+
+	if a.AD != nil && resp.AD != *a.AD {
+		fail(StatusCritical, "AD bit is %v, expected %v", resp.AD, *a.AD)
+	}
+
+	var latency time.Duration
+	if resp.Request != nil {
+		latency = resp.Request.Latency
+	}
+	if a.MaxLatency > 0 && latency > a.MaxLatency {
+		fail(StatusWarning, "query took %s, more than %s", latency.Round(time.Millisecond), a.MaxLatency)
+	}
+
+	minTTL := -1
+	for _, ans := range resp.Answer {
+		if minTTL < 0 || ans.TTL < minTTL {
+			minTTL = ans.TTL
+		}
+	}
+	if a.MinTTL > 0 && minTTL >= 0 && minTTL < a.MinTTL {
+		fail(StatusWarning, "TTL %d is below %d", minTTL, a.MinTTL)
+	}
+
+	r.Summary = fmt.Sprintf("%s, %d answers in %s", resp.StatusName, len(resp.Answer), latency.Round(time.Millisecond))
+	if len(resp.Question) > 0 {
+		r.Summary = resp.Question[0].Name + " " + r.Summary
+	}
+	r.Perfdata = append(r.Perfdata, perfdata("time", fmt.Sprintf("%fs", latency.Seconds()), threshold(a.MaxLatency > 0, fmt.Sprintf("%f", a.MaxLatency.Seconds()))))
+	if minTTL >= 0 {
+		r.Perfdata = append(r.Perfdata, perfdata("ttl", fmt.Sprintf("%ds", minTTL), threshold(a.MinTTL > 0, fmt.Sprintf("%d:", a.MinTTL))))
+	}
+	r.Perfdata = append(r.Perfdata, perfdata("answers", fmt.Sprint(len(resp.Answer)), ""))
+	return r
+}
+
+// answerMatcher returns the function that matches answer data for an exact:, contains: or regex:
+// assertion, defaulting to an exact match without a prefix
+func answerMatcher(assertion string) (func(string) bool, error) {
+	mode, value := "exact", assertion
+	if i := strings.Index(assertion, ":"); i > 0 {
+		switch assertion[:i] {
+		case "exact", "contains", "regex":
+			mode, value = assertion[:i], assertion[i+1:]
+		}
+	}
+
+	switch mode {
+	case "contains":
+		return func(data string) bool { return strings.Contains(data, value) }, nil
+	case "regex":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling the answer regex: %s, err: %w", value, err)
+		}
+		return re.MatchString, nil
+	default:
+		return func(data string) bool {
+			return strings.EqualFold(strings.TrimSuffix(data, "."), strings.TrimSuffix(value, "."))
+		}, nil
+	}
+}
+
+// perfdata formats a value in the 'label'=value;warn;crit;min format, with the thresholds as
+// warnings since only the data assertions are critical
+func perfdata(label, value, warn string) string {
+	return fmt.Sprintf("%s=%s;%s;;0", label, value, warn)
+}
+
+func threshold(set bool, value string) string {
+	if !set {
+		return ""
+	}
+	return value
+}
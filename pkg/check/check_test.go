@@ -0,0 +1,195 @@
+package check
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+)
+
+func response(status int, ad bool, latency time.Duration, answers ...common.QueryResponseAnswer) *common.QueryResponse {
+	resp := &common.QueryResponse{
+		StatusCode: status,
+		AD:         ad,
+		Question:   []common.QueryResponseQuestion{{Name: "example.com", Type: 1}},
+		Answer:     answers,
+		Request:    &common.RequestInfo{Latency: latency},
+	}
+	resp.DetermineStatusMessage()
+	return resp
+}
+
+func answer(ttl int, data string) common.QueryResponseAnswer {
+	return common.QueryResponseAnswer{Name: "example.com.", Type: 1, TTL: ttl, Data: data}
+}
+
+func TestEvaluate(t *testing.T) {
+	yes, no := true, false
+	ok := response(0, true, 25*time.Millisecond, answer(300, "192.0.2.1"), answer(60, "192.0.2.2"))
+
+	for _, tt := range []struct {
+		name       string
+		assertions Assertions
+		resp       *common.QueryResponse
+		want       Result
+		line       string
+	}{
+		{
+			name:       "ok",
+			assertions: Assertions{RCode: "NOERROR", Answer: "192.0.2.2", AD: &yes, MaxLatency: time.Second, MinTTL: 30},
+			resp:       ok,
+			want: Result{
+				Status:   StatusOK,
+				Summary:  "example.com NOERROR, 2 answers in 25ms",
+				Perfdata: []string{"time=0.025000s;1.000000;;0", "ttl=60s;30:;;0", "answers=2;;;0"},
+			},
+			line: "DNS OK - example.com NOERROR, 2 answers in 25ms | time=0.025000s;1.000000;;0 ttl=60s;30:;;0 answers=2;;;0",
+		},
+		{
+			name:       "ok without thresholds",
+			assertions: Assertions{RCode: "0"},
+			resp:       ok,
+			want: Result{
+				Status:   StatusOK,
+				Summary:  "example.com NOERROR, 2 answers in 25ms",
+				Perfdata: []string{"time=0.025000s;;;0", "ttl=60s;;;0", "answers=2;;;0"},
+			},
+			line: "DNS OK - example.com NOERROR, 2 answers in 25ms | time=0.025000s;;;0 ttl=60s;;;0 answers=2;;;0",
+		},
+		{
+			name:       "answer matchers",
+			assertions: Assertions{Answer: "contains:0.2.2"},
+			resp:       ok,
+			want: Result{
+				Status:   StatusOK,
+				Summary:  "example.com NOERROR, 2 answers in 25ms",
+				Perfdata: []string{"time=0.025000s;;;0", "ttl=60s;;;0", "answers=2;;;0"},
+			},
+		},
+		{
+			name:       "slow and short lived",
+			assertions: Assertions{MaxLatency: 10 * time.Millisecond, MinTTL: 120},
+			resp:       ok,
+			want: Result{
+				Status:   StatusWarning,
+				Summary:  "example.com NOERROR, 2 answers in 25ms",
+				Failures: []string{"query took 25ms, more than 10ms", "TTL 60 is below 120"},
+				Perfdata: []string{"time=0.025000s;0.010000;;0", "ttl=60s;120:;;0", "answers=2;;;0"},
+			},
+			line: "DNS WARNING - query took 25ms, more than 10ms; TTL 60 is below 120 | time=0.025000s;0.010000;;0 ttl=60s;120:;;0 answers=2;;;0",
+		},
+		{
+			name:       "wrong data outranks a warning",
+			assertions: Assertions{RCode: "NOERROR", Answer: "regex:^198\\.", AD: &no, MaxLatency: 10 * time.Millisecond},
+			resp:       ok,
+			want: Result{
+				Status:  StatusCritical,
+				Summary: "example.com NOERROR, 2 answers in 25ms",
+				Failures: []string{
+					"no answer matches regex:^198\\.",
+					"AD bit is true, expected false",
+					"query took 25ms, more than 10ms",
+				},
+				Perfdata: []string{"time=0.025000s;0.010000;;0", "ttl=60s;;;0", "answers=2;;;0"},
+			},
+			line: "DNS CRITICAL - no answer matches regex:^198\\.; AD bit is true, expected false; query took 25ms, more than 10ms | time=0.025000s;0.010000;;0 ttl=60s;;;0 answers=2;;;0",
+		},
+		{
+			name:       "wrong rcode without answers",
+			assertions: Assertions{RCode: "NOERROR", MinTTL: 60},
+			resp:       response(3, false, 1500*time.Millisecond),
+			want: Result{
+				Status:   StatusCritical,
+				Summary:  "example.com NXDOMAIN, 0 answers in 1.5s",
+				Failures: []string{"rcode NXDOMAIN, expected NOERROR"},
+				Perfdata: []string{"time=1.500000s;;;0", "answers=0;;;0"},
+			},
+			line: "DNS CRITICAL - rcode NXDOMAIN, expected NOERROR | time=1.500000s;;;0 answers=0;;;0",
+		},
+		{
+			name:       "invalid assertion",
+			assertions: Assertions{RCode: "BOGUS"},
+			resp:       ok,
+			want:       Result{Status: StatusUnknown, Summary: "BOGUS is an unknown response code"},
+			line:       "DNS UNKNOWN - BOGUS is an unknown response code",
+		},
+	} {
+		got := tt.assertions.Evaluate(tt.resp)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Evaluate() =\n%#v\nwant\n%#v", tt.name, got, tt.want)
+		}
+		if tt.line != "" && got.String() != tt.line {
+			t.Errorf("%s: String() =\n%s\nwant\n%s", tt.name, got, tt.line)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, a := range []Assertions{
+		{},
+		{RCode: "nxdomain"},
+		{RCode: "3"},
+		{Answer: "example.com."},
+		{Answer: "exact:192.0.2.1"},
+		{Answer: "regex:^192\\.0\\.2\\."},
+		{Answer: "unknown:prefix"},
+	} {
+		if err := a.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", a, err)
+		}
+	}
+
+	for _, a := range []Assertions{
+		{RCode: "BOGUS"},
+		{Answer: "regex:("},
+	} {
+		if err := a.Validate(); err == nil {
+			t.Errorf("Validate(%+v) error = nil", a)
+		}
+	}
+}
+
+func TestAnswerMatcher(t *testing.T) {
+	for _, tt := range []struct {
+		assertion, data string
+		want            bool
+	}{
+		{"www.example.com", "WWW.example.com.", true},
+		{"exact:192.0.2.1", "192.0.2.10", false},
+		{"contains:0.2.1", "192.0.2.10", true},
+		{"regex:^192\\.0\\.2\\.1$", "192.0.2.10", false},
+		{"regex:^192\\.0\\.2\\.1", "192.0.2.10", true},
+		{"unknown:prefix", "unknown:prefix", true},
+	} {
+		match, err := answerMatcher(tt.assertion)
+		if err != nil {
+			t.Fatalf("answerMatcher(%q) error = %v", tt.assertion, err)
+		}
+		if got := match(tt.data); got != tt.want {
+			t.Errorf("answerMatcher(%q)(%q) = %v, want %v", tt.assertion, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestResultStatus(t *testing.T) {
+	for _, tt := range []struct {
+		result Result
+		status Status
+		code   int
+		line   string
+	}{
+		{Failed(errors.New("connection refused")), StatusCritical, 2, "DNS CRITICAL - connection refused"},
+		{Invalid(errors.New("bad regex")), StatusUnknown, 3, "DNS UNKNOWN - bad regex"},
+		{Result{Status: StatusOK, Summary: "fine"}, StatusOK, 0, "DNS OK - fine"},
+		{Result{Status: StatusWarning, Summary: "slow"}, StatusWarning, 1, "DNS WARNING - slow"},
+	} {
+		if tt.result.Status != tt.status || int(tt.result.Status) != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.line, tt.result.Status, tt.code)
+		}
+		if got := tt.result.String(); got != tt.line {
+			t.Errorf("String() = %q, want %q", got, tt.line)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cloudflare
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+)
+
+func TestDo(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{
+		AD:      true,
+		Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "93.184.216.34"}},
+	})
+
+	resp, err := QueryRequest{Resource: "example.com", ResourceType: "A", ShowDNSSEC: true}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	req := s.LastRequest()
+	if req.Host != "cloudflare-dns.com" || req.URL.Path != "/dns-query" {
+		t.Errorf("request sent to %s%s, want cloudflare-dns.com/dns-query", req.Host, req.URL.Path)
+	}
+	want := url.Values{"name": {"example.com"}, "type": {"A"}, "cd": {"false"}, "do": {"true"}}
+	if got := req.URL.Query(); !reflect.DeepEqual(got, want) {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+	if got := req.Header.Get("Accept"); got != "application/dns-json" {
+		t.Errorf("Accept = %q, want application/dns-json", got)
+	}
+
+	if resp.StatusName != "NOERROR" || !resp.AD {
+		t.Errorf("status = %s, AD = %v, want NOERROR, true", resp.StatusName, resp.AD)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].Data != "93.184.216.34" {
+		t.Errorf("answer = %+v, want a single 93.184.216.34 record", resp.Answer)
+	}
+}
+
+func TestDoIDN(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+
+	resp, err := QueryRequest{Resource: "bücher.example", ResourceType: "A"}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if got := s.LastRequest().URL.Query().Get("name"); got != "xn--bcher-kva.example" {
+		t.Errorf("name = %q, want xn--bcher-kva.example", got)
+	}
+	if resp.StatusName != "NXDOMAIN" {
+		t.Errorf("status = %s, want NXDOMAIN", resp.StatusName)
+	}
+}
@@ -0,0 +1,166 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// The blocking verdicts
+const (
+	VerdictBlocked      = "BLOCKED"
+	VerdictNotBlocked   = "NOT BLOCKED"
+	VerdictInconclusive = "INCONCLUSIVE"
+)
+
+// SinkholeAddresses are the answers filtering resolvers return in place of the real addresses
+var SinkholeAddresses = []string{"0.0.0.0", "::", "127.0.0.1", "::1"}
+
+// BlockPageAddresses are the addresses of the pages that filtering services redirect blocked
+// names to, such as the OpenDNS block pages
+var BlockPageAddresses = []string{
+	"146.112.61.104",
+	"146.112.61.105",
+	"146.112.61.106",
+	"146.112.61.107",
+	"146.112.61.108",
+	"146.112.61.110",
+}
+
+// BlockingVerdict is the outcome of checking whether a response was filtered
+type BlockingVerdict struct {
+	Verdict   string   `json:"verdict"`
+	Reference string   `json:"reference,omitempty"`
+	Evidence  []string `json:"evidence"`
+}
+
+// DetectBlocking will look for the signs of a filtered response, sinkhole and block page
+// addresses and the blocking Extended DNS Errors, compare it to the response of an unfiltered
+// reference provider and assign the verdict along with the evidence it is based on
+//
+// Arguments:
+//     reference     (string):         The name of the reference provider
+//     referenceResp (*QueryResponse): The reference provider's response, or nil if it failed
+//
+// Returns:
+//     None
+func (q *QueryResponse) DetectBlocking(reference string, referenceResp *QueryResponse) {
+	q.Blocking = q.detectBlocking(reference, referenceResp)
+}
+
+func (q *QueryResponse) detectBlocking(reference string, referenceResp *QueryResponse) *BlockingVerdict {
+	v := &BlockingVerdict{Reference: reference}
+	blocked := false
+
+	for _, e := range q.ExtendedErrors {
+		switch e.InfoCode {
+		case 15, 16, 17, 18:
+			blocked = true
+			evidence := fmt.Sprintf("Extended DNS Error %d (%s)", e.InfoCode, e.Name)
+			if e.ExtraText != "" {
+				evidence += ": " + e.ExtraText
+			}
+			v.Evidence = append(v.Evidence, evidence)
+		}
+	}
+
+	sinkholed := false
+	for _, a := range q.Answer {
+		if a.Type != 1 && a.Type != 28 {
+			continue
+		}
+		switch {
+		case containsAddress(SinkholeAddresses, a.Data):
+			sinkholed = true
+			v.Evidence = append(v.Evidence, fmt.Sprintf("%s is a sinkhole address", a.Data))
+		case containsAddress(BlockPageAddresses, a.Data):
+			sinkholed = true
+			v.Evidence = append(v.Evidence, fmt.Sprintf("%s is a known block page address", a.Data))
+		}
+	}
+
+	if referenceResp == nil {
+		v.Evidence = append(v.Evidence, fmt.Sprintf("the reference provider %s did not respond", reference))
+		switch {
+		case blocked || sinkholed:
+			v.Verdict = VerdictBlocked
+		default:
+			v.Verdict = VerdictInconclusive
+		}
+		return v
+	}
+
+	refAnswers := answerSet(referenceResp.Answer)
+	answers := answerSet(q.Answer)
+	switch {
+	case sinkholed && refAnswers == answers:
+		// The name really does point at the sinkhole address
+		sinkholed = false
+		v.Evidence = append(v.Evidence, fmt.Sprintf("%s returns the same answer, so it is the real address", reference))
+	case refAnswers == answers:
+		v.Evidence = append(v.Evidence, fmt.Sprintf("%s returns the same answer", reference))
+	case refAnswers != "" && answers == "":
+		blocked = true
+		v.Evidence = append(v.Evidence, fmt.Sprintf("%s with no answers while %s answers %s", q.StatusName, reference, refAnswers))
+	case refAnswers != "":
+		v.Evidence = append(v.Evidence, fmt.Sprintf("%s answers %s", reference, refAnswers))
+	default:
+		v.Evidence = append(v.Evidence, fmt.Sprintf("%s answers %s with no records", reference, referenceResp.StatusName))
+	}
+
+	if blocked || sinkholed {
+		v.Verdict = VerdictBlocked
+	} else {
+		v.Verdict = VerdictNotBlocked
+	}
+	return v
+}
+
+// answerSet returns the address records of the answers joined in a stable order, so that two
+// responses can be compared regardless of record order
+func answerSet(answers []QueryResponseAnswer) string {
+	var data []string
+	for _, a := range answers {
+		if a.Type == 1 || a.Type == 28 || a.Type == 5 {
+			data = append(data, strings.ToLower(strings.TrimSuffix(a.Data, ".")))
+		}
+	}
+	sort.Strings(data)
+	return strings.Join(data, ", ")
+}
+
+func containsAddress(list []string, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, l := range list {
+		if ip.Equal(net.ParseIP(l)) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintBlocking will print out the blocking verdict and its evidence
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (q QueryResponse) PrintBlocking() {
+	v := q.Blocking
+	if v == nil {
+		return
+	}
+
+	fmt.Println("Blocking:")
+	fmt.Printf("  Verdict:            %s\n", v.Verdict)
+	fmt.Printf("  Reference:          %s\n", v.Reference)
+	fmt.Println("  Evidence:")
+	for _, e := range v.Evidence {
+		fmt.Printf("    %s\n", e)
+	}
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectBlocking(t *testing.T) {
+	a := func(t int, data string) QueryResponseAnswer {
+		return QueryResponseAnswer{Name: "example.com.", Type: t, TTL: 300, Data: data}
+	}
+	answer := func(answers ...QueryResponseAnswer) *QueryResponse {
+		return &QueryResponse{StatusName: "NOERROR", Answer: answers}
+	}
+	real := answer(a(1, "192.0.2.1"))
+
+	for _, tt := range []struct {
+		name     string
+		resp     *QueryResponse
+		ref      *QueryResponse
+		verdict  string
+		evidence []string
+	}{
+		{
+			name:     "same answer",
+			resp:     answer(a(1, "192.0.2.1")),
+			ref:      real,
+			verdict:  VerdictNotBlocked,
+			evidence: []string{"reference returns the same answer"},
+		},
+		{
+			name:    "sinkhole",
+			resp:    answer(a(1, "0.0.0.0")),
+			ref:     real,
+			verdict: VerdictBlocked,
+			evidence: []string{
+				"0.0.0.0 is a sinkhole address",
+				"reference answers 192.0.2.1",
+			},
+		},
+		{
+			name:    "ipv6 sinkhole",
+			resp:    answer(a(28, "0:0:0:0:0:0:0:0")),
+			ref:     answer(a(28, "2001:db8::1")),
+			verdict: VerdictBlocked,
+			evidence: []string{
+				"0:0:0:0:0:0:0:0 is a sinkhole address",
+				"reference answers 2001:db8::1",
+			},
+		},
+		{
+			name:     "real sinkhole address",
+			resp:     answer(a(1, "127.0.0.1")),
+			ref:      answer(a(1, "127.0.0.1")),
+			verdict:  VerdictNotBlocked,
+			evidence: []string{"127.0.0.1 is a sinkhole address", "reference returns the same answer, so it is the real address"},
+		},
+		{
+			name:    "block page",
+			resp:    answer(a(1, "146.112.61.106")),
+			ref:     real,
+			verdict: VerdictBlocked,
+			evidence: []string{
+				"146.112.61.106 is a known block page address",
+				"reference answers 192.0.2.1",
+			},
+		},
+		{
+			name: "extended error",
+			resp: &QueryResponse{
+				StatusName:     "NOERROR",
+				ExtendedErrors: []ExtendedError{{InfoCode: 15, Name: "Blocked", ExtraText: "policy"}, {InfoCode: 3, Name: "Stale Answer"}},
+			},
+			ref:     real,
+			verdict: VerdictBlocked,
+			evidence: []string{
+				"Extended DNS Error 15 (Blocked): policy",
+				"NOERROR with no answers while reference answers 192.0.2.1",
+			},
+		},
+		{
+			name:     "censored without text",
+			resp:     &QueryResponse{StatusName: "NXDOMAIN", ExtendedErrors: []ExtendedError{{InfoCode: 16, Name: "Censored"}}},
+			ref:      &QueryResponse{StatusName: "NXDOMAIN"},
+			verdict:  VerdictBlocked,
+			evidence: []string{"Extended DNS Error 16 (Censored)", "reference returns the same answer"},
+		},
+		{
+			name:     "missing answer",
+			resp:     &QueryResponse{StatusName: "NXDOMAIN"},
+			ref:      real,
+			verdict:  VerdictBlocked,
+			evidence: []string{"NXDOMAIN with no answers while reference answers 192.0.2.1"},
+		},
+		{
+			name:     "different answer",
+			resp:     answer(a(5, "cdn.example.net."), a(1, "198.51.100.1")),
+			ref:      real,
+			verdict:  VerdictNotBlocked,
+			evidence: []string{"reference answers 192.0.2.1"},
+		},
+		{
+			name:     "same answer in another order",
+			resp:     answer(a(1, "192.0.2.2"), a(5, "Example.net."), a(1, "192.0.2.1")),
+			ref:      answer(a(5, "example.net"), a(1, "192.0.2.1"), a(1, "192.0.2.2")),
+			verdict:  VerdictNotBlocked,
+			evidence: []string{"reference returns the same answer"},
+		},
+		{
+			name:     "reference without answers",
+			resp:     real,
+			ref:      &QueryResponse{StatusName: "SERVFAIL"},
+			verdict:  VerdictNotBlocked,
+			evidence: []string{"reference answers SERVFAIL with no records"},
+		},
+		{
+			name:     "reference failed",
+			resp:     real,
+			verdict:  VerdictInconclusive,
+			evidence: []string{"the reference provider reference did not respond"},
+		},
+		{
+			name:     "reference failed on a sinkhole",
+			resp:     answer(a(1, "::")),
+			verdict:  VerdictBlocked,
+			evidence: []string{":: is a sinkhole address", "the reference provider reference did not respond"},
+		},
+	} {
+		resp := *tt.resp
+		resp.DetectBlocking("reference", tt.ref)
+		want := &BlockingVerdict{Verdict: tt.verdict, Reference: "reference", Evidence: tt.evidence}
+		if !reflect.DeepEqual(resp.Blocking, want) {
+			t.Errorf("%s: DetectBlocking() = %+v, want %+v", tt.name, resp.Blocking, want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the request timeout used when none has been configured
+const DefaultTimeout = 10 * time.Second
+
+// Client is the HTTP client shared by every provider
+var Client = &http.Client{
+	Timeout:   DefaultTimeout,
+	Transport: newTransport(),
+}
+
+var bootstrap = struct {
+	sync.RWMutex
+	hosts map[string][]string
+}{hosts: make(map[string][]string)}
+
+// SetBootstrap sets the IP addresses used to connect to a DoH endpoint's host, so that reaching
+// the endpoint never depends on the system resolver
+//
+// Arguments:
+//     host  (string):   The host name of the DoH endpoint
+//     addrs ([]string): The IP addresses to dial instead of resolving host, nil to use the system resolver
+//
+// Returns:
+//     (error): An error if any of the addresses is not an IP address, nil otherwise
+func SetBootstrap(host string, addrs []string) error {
+	for _, a := range addrs {
+		if net.ParseIP(a) == nil {
+			return fmt.Errorf("bootstrap address %s for %s is not an IP address", a, host)
+		}
+	}
+
+	bootstrap.Lock()
+	defer bootstrap.Unlock()
+	if len(addrs) == 0 {
+		delete(bootstrap.hosts, strings.ToLower(host))
+		return nil
+	}
+	bootstrap.hosts[strings.ToLower(host)] = append([]string(nil), addrs...)
+	return nil
+}
+
+// Bootstrap returns the IP addresses configured for a DoH endpoint's host
+//
+// Arguments:
+//     host (string): The host name of the DoH endpoint
+//
+// Returns:
+//     ([]string): The bootstrap addresses, or nil if the system resolver is used
+func Bootstrap(host string) []string {
+	bootstrap.RLock()
+	defer bootstrap.RUnlock()
+	return append([]string(nil), bootstrap.hosts[strings.ToLower(host)]...)
+}
+
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{
+		VerifyConnection: verifyPins,
+	}
+	t.DialContext = bootstrapDialer{&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}}.DialContext
+	return t
+}
+
+type bootstrapDialer struct {
+	*net.Dialer
+}
+
+// DialContext dials the bootstrap addresses of the host in turn, falling back to the system
+// resolver only for hosts that have no bootstrap addresses
+func (d bootstrapDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := Bootstrap(host)
+	if len(addrs) == 0 {
+		return d.Dialer.DialContext(ctx, network, address)
+	}
+
+	var errs []string
+	for _, a := range addrs {
+		conn, err := d.Dialer.DialContext(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("error dialing the bootstrap addresses for %s, err: %s", host, strings.Join(errs, "; "))
+}
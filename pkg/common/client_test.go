@@ -0,0 +1,113 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBootstrapDialer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A resolver that fails every lookup, and counts them, stands in for the system resolver
+	var lookups int32
+	d := bootstrapDialer{&net.Dialer{Resolver: &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			atomic.AddInt32(&lookups, 1)
+			return nil, errors.New("the system resolver was used")
+		},
+	}}}
+
+	// Nothing listens on 127.0.0.2, so the dialer has to move on to the next address
+	if err := SetBootstrap("DoH.invalid", []string{"127.0.0.2", "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetBootstrap("doh.invalid", nil)
+
+	conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("doh.invalid", port))
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	if got := conn.RemoteAddr().String(); got != srv.Listener.Addr().String() {
+		t.Errorf("DialContext() connected to %s, want %s", got, srv.Listener.Addr())
+	}
+	conn.Close()
+	if n := atomic.LoadInt32(&lookups); n != 0 {
+		t.Errorf("the system resolver was used %d times for a bootstrapped host", n)
+	}
+
+	if err := SetBootstrap("doh.invalid", []string{"127.0.0.2", "127.0.0.3"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.DialContext(context.Background(), "tcp", net.JoinHostPort("doh.invalid", port))
+	if err == nil || !strings.HasPrefix(err.Error(), "error dialing the bootstrap addresses for doh.invalid") {
+		t.Errorf("DialContext() with no reachable address error = %v", err)
+	}
+	if n := atomic.LoadInt32(&lookups); n != 0 {
+		t.Errorf("the system resolver was used %d times for a bootstrapped host", n)
+	}
+
+	// Hosts without bootstrap addresses are resolved as usual
+	if _, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("other.invalid", port)); err == nil {
+		t.Error("DialContext() of an unresolvable host error = nil")
+	}
+	if atomic.LoadInt32(&lookups) == 0 {
+		t.Error("the system resolver was not used for a host without bootstrap addresses")
+	}
+}
+
+func TestBootstrapTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetBootstrap("doh.invalid", []string{"127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetBootstrap("doh.invalid", nil)
+
+	// The .invalid name can never be resolved, so only the bootstrap address can reach the server
+	tr := newTransport()
+	tr.TLSClientConfig.InsecureSkipVerify = true
+	defer tr.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: tr}).Get("https://" + net.JoinHostPort("doh.invalid", port) + "/")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Get() status = %s, want 200 OK", resp.Status)
+	}
+}
+
+func TestSetBootstrap(t *testing.T) {
+	defer SetBootstrap("doh.invalid", nil)
+
+	if err := SetBootstrap("doh.invalid", []string{"192.0.2.1", "doh.example"}); err == nil {
+		t.Error("SetBootstrap() with a host name error = nil")
+	}
+	if got := Bootstrap("doh.invalid"); len(got) != 0 {
+		t.Errorf("Bootstrap() after an invalid address = %v, want none", got)
+	}
+
+	if err := SetBootstrap("doh.invalid", []string{"192.0.2.1", "2001:db8::1"}); err != nil {
+		t.Fatalf("SetBootstrap() error = %v", err)
+	}
+	if got := Bootstrap("DOH.invalid"); len(got) != 2 || got[0] != "192.0.2.1" || got[1] != "2001:db8::1" {
+		t.Errorf("Bootstrap() = %v, want [192.0.2.1 2001:db8::1]", got)
+	}
+}
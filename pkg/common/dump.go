@@ -0,0 +1,391 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dumpWidth is the number of octets shown on each line of a hex dump
+const dumpWidth = 16
+
+// DumpMessage formats a wire-format DNS message as a hex dump, with every field on its own line
+// and annotated with its name and decoded value. Octets past the end of what can be parsed are
+// dumped without annotations
+//
+// Arguments:
+//     b ([]byte): The packed DNS message
+//
+// Returns:
+//     (string): The annotated hex dump
+func DumpMessage(b []byte) string {
+	d := &dumper{b: b}
+
+	// The parsed message supplies the presentation format of the record data
+	var m dnsmessage.Message
+	if err := m.Unpack(b); err == nil {
+		d.msg = &m
+	}
+	d.message()
+
+	if d.off < len(b) {
+		d.field(len(b)-d.off, "Trailing data")
+	}
+	return d.out.String()
+}
+
+type dumper struct {
+	b   []byte
+	off int
+	msg *dnsmessage.Message
+	out strings.Builder
+}
+
+// field dumps the next n octets with an annotation, reporting false when there are fewer left
+func (d *dumper) field(n int, format string, args ...interface{}) bool {
+	if d.off+n > len(d.b) {
+		return false
+	}
+
+	note := fmt.Sprintf(format, args...)
+	for i := 0; i < n; i += dumpWidth {
+		end := i + dumpWidth
+		if end > n {
+			end = n
+		}
+		var hex []string
+		for _, c := range d.b[d.off+i : d.off+end] {
+			hex = append(hex, fmt.Sprintf("%02x", c))
+		}
+		fmt.Fprintf(&d.out, "%04x  %-*s  %s\n", d.off+i, dumpWidth*3-1, strings.Join(hex, " "), note)
+		note = ""
+	}
+	d.off += n
+	return true
+}
+
+func (d *dumper) uint16() (uint16, bool) {
+	if d.off+2 > len(d.b) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(d.b[d.off:]), true
+}
+
+func (d *dumper) message() {
+	if len(d.b) < 12 {
+		return
+	}
+
+	h := binary.BigEndian.Uint16(d.b[2:])
+	counts := [4]int{
+		int(binary.BigEndian.Uint16(d.b[4:])),
+		int(binary.BigEndian.Uint16(d.b[6:])),
+		int(binary.BigEndian.Uint16(d.b[8:])),
+		int(binary.BigEndian.Uint16(d.b[10:])),
+	}
+	d.field(2, "ID: %d", binary.BigEndian.Uint16(d.b))
+	d.field(2, "Flags: %s", headerFlags(h))
+	d.field(2, "Questions: %d", counts[0])
+	d.field(2, "Answers: %d", counts[1])
+	d.field(2, "Authority: %d", counts[2])
+	d.field(2, "Additional: %d", counts[3])
+
+	for i := 0; i < counts[0]; i++ {
+		if !d.question(i + 1) {
+			return
+		}
+	}
+	for s, section := range []string{"Answer", "Authority", "Additional"} {
+		for i := 0; i < counts[s+1]; i++ {
+			if !d.resource(section, s, i) {
+				return
+			}
+		}
+	}
+}
+
+func (d *dumper) question(n int) bool {
+	name, next, ok := readName(d.b, d.off)
+	if !ok || !d.field(next-d.off, "Question %d: %s", n, name) {
+		return false
+	}
+	t, ok := d.uint16()
+	if !ok || !d.field(2, "  Type: %s", typeName(int(t))) {
+		return false
+	}
+	c, ok := d.uint16()
+	return ok && d.field(2, "  Class: %s", className(c))
+}
+
+func (d *dumper) resource(section string, s, i int) bool {
+	name, next, ok := readName(d.b, d.off)
+	if !ok || !d.field(next-d.off, "%s %d: %s", section, i+1, name) {
+		return false
+	}
+	t, ok := d.uint16()
+	if !ok || !d.field(2, "  Type: %s", typeName(int(t))) {
+		return false
+	}
+
+	if dnsmessage.Type(t) == dnsmessage.TypeOPT {
+		size, ok := d.uint16()
+		if !ok || !d.field(2, "  UDP Payload Size: %d", size) {
+			return false
+		}
+		if d.off+4 > len(d.b) {
+			return false
+		}
+		ttl := binary.BigEndian.Uint32(d.b[d.off:])
+		flags := "none"
+		if ttl&0x8000 != 0 {
+			flags = "do"
+		}
+		d.field(4, "  Extended RCODE: %d, Version: %d, Flags: %s", ttl>>24, (ttl>>16)&0xff, flags)
+		length, ok := d.uint16()
+		if !ok || !d.field(2, "  Length: %d", length) {
+			return false
+		}
+		return d.options(d.off + int(length))
+	}
+
+	c, ok := d.uint16()
+	if !ok || !d.field(2, "  Class: %s", className(c)) {
+		return false
+	}
+	if d.off+4 > len(d.b) {
+		return false
+	}
+	d.field(4, "  TTL: %d", binary.BigEndian.Uint32(d.b[d.off:]))
+	length, ok := d.uint16()
+	if !ok || !d.field(2, "  Length: %d", length) {
+		return false
+	}
+	if length == 0 {
+		return true
+	}
+	return d.field(int(length), "  Data: %s", d.rdata(s, i))
+}
+
+// options dumps the EDNS0 options of an OPT record, which end at end
+func (d *dumper) options(end int) bool {
+	for d.off < end {
+		code, ok := d.uint16()
+		if !ok || !d.field(2, "  Option: %s", optionName(code)) {
+			return false
+		}
+		length, ok := d.uint16()
+		if !ok || !d.field(2, "    Length: %d", length) {
+			return false
+		}
+		if length > 0 && !d.field(int(length), "    Data") {
+			return false
+		}
+	}
+	return d.off == end
+}
+
+// rdata returns the presentation format of the data of the i-th record of section s
+func (d *dumper) rdata(s, i int) string {
+	if d.msg == nil {
+		return ""
+	}
+	sections := [][]dnsmessage.Resource{d.msg.Answers, d.msg.Authorities, d.msg.Additionals}
+	if i >= len(sections[s]) {
+		return ""
+	}
+	return RDataString(sections[s][i].Body)
+}
+
+// readName reads the possibly compressed domain name at off, returning it in presentation format
+// along with the offset that follows it in the message
+func readName(b []byte, off int) (string, int, bool) {
+	var (
+		labels []string
+		next   = -1
+	)
+	for hops := 0; hops < 64; hops++ {
+		if off >= len(b) {
+			return "", 0, false
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, true
+		case l&0xc0 == 0xc0:
+			if off+2 > len(b) {
+				return "", 0, false
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+		default:
+			if off+1+l > len(b) {
+				return "", 0, false
+			}
+			labels = append(labels, string(b[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+	return "", 0, false
+}
+
+func headerFlags(h uint16) string {
+	var flags []string
+	for _, f := range []struct {
+		bit  uint16
+		name string
+	}{{1 << 15, "qr"}, {1 << 10, "aa"}, {1 << 9, "tc"}, {1 << 8, "rd"}, {1 << 7, "ra"}, {1 << 5, "ad"}, {1 << 4, "cd"}} {
+		if h&f.bit != 0 {
+			flags = append(flags, f.name)
+		}
+	}
+	q := QueryResponse{StatusCode: int(h & 0x0f)}
+	q.DetermineStatusMessage()
+	return fmt.Sprintf("%s, Opcode: %d, RCODE: %s", strings.Join(flags, " "), (h>>11)&0x0f, q.StatusName)
+}
+
+func typeName(t int) string {
+	a := QueryResponseAnswer{Type: t}
+	a.DetermineTypeNameAndMeaning()
+	return fmt.Sprintf("%s (%d)", a.TypeName, t)
+}
+
+func className(c uint16) string {
+	switch c {
+	case 1:
+		return "IN (1)"
+	case 3:
+		return "CH (3)"
+	case 4:
+		return "HS (4)"
+	case 255:
+		return "ANY (255)"
+	default:
+		return fmt.Sprintf("CLASS%d", c)
+	}
+}
+
+func optionName(code uint16) string {
+	switch code {
+	case OptionClientSubnet:
+		return fmt.Sprintf("Client Subnet (%d)", code)
+	case 10:
+		return fmt.Sprintf("Cookie (%d)", code)
+	case OptionPadding:
+		return fmt.Sprintf("Padding (%d)", code)
+	case OptionExtendedError:
+		return fmt.Sprintf("Extended DNS Error (%d)", code)
+	default:
+		return fmt.Sprintf("Option %d", code)
+	}
+}
+
+// QueryMessage returns the wire-format query message a GET request carried in its dns parameter
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     ([]byte): The packed DNS query, or nil if the request was not a wire-format GET
+func (r RequestInfo) QueryMessage() []byte {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil
+	}
+	dns := u.Query().Get("dns")
+	if dns == "" {
+		return nil
+	}
+
+	// RFC 8484 leaves out the base64 padding, but accept it from endpoints that expect it
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(dns, "="))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// PrintQuery will print out the request exactly as it was sent: the method, the URL, the headers
+// and, for wire-format queries, a dump of the query message
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (q QueryResponse) PrintQuery() {
+	if q.Request == nil {
+		return
+	}
+
+	fmt.Println("Sent Query:")
+	fmt.Printf("  %s %s\n", q.Request.Method, q.Request.URL)
+	printHeader(q.Request.Header)
+	if msg := q.Request.QueryMessage(); msg != nil {
+		fmt.Println("  Message:")
+		printIndented(DumpMessage(msg))
+	}
+}
+
+// PrintRaw will print out the HTTP response the query response was decoded from, with a dump of
+// the message for wire-format responses and the body as received otherwise
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (q QueryResponse) PrintRaw() {
+	if q.Response == nil {
+		return
+	}
+	q.Response.Print()
+}
+
+// Print will print out the HTTP response, with a dump of the message for wire-format responses
+// and the body as received otherwise
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (r *ResponseInfo) Print() {
+	fmt.Println("Raw Response:")
+	fmt.Printf("  %s %s\n", r.Proto, r.Status)
+	printHeader(r.Header)
+	fmt.Println("  Body:")
+	if strings.HasPrefix(r.Header.Get("Content-Type"), MediaTypeDNSMessage) {
+		printIndented(DumpMessage(r.Body))
+		return
+	}
+	printIndented(string(r.Body))
+}
+
+func printHeader(h map[string][]string) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Printf("  %s: %s\n", k, v)
+		}
+	}
+}
+
+func printIndented(s string) {
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDumpMessage(t *testing.T) {
+	resp := new(QueryResponse)
+	err := resp.Unmarshal(ioutil.NopCloser(strings.NewReader(`{
+		"Status": 0, "RD": true, "RA": true,
+		"Question": [{"name": "example.com.", "type": 1}],
+		"Answer": [{"name": "example.com.", "type": 1, "TTL": 300, "data": "93.184.216.34"}],
+		"Comment": ["EDE(15): Blocked (policy)"]
+	}`)))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	b, err := resp.MarshalWire()
+	if err != nil {
+		t.Fatalf("MarshalWire() error = %v", err)
+	}
+
+	dump := DumpMessage(b)
+	for _, want := range []string{
+		"0000  00 00 ",
+		"Flags: qr rd ra, Opcode: 0, RCODE: NOERROR",
+		"Question 1: example.com.",
+		"  Type: A (1)",
+		"  Class: IN (1)",
+		"  TTL: 300",
+		"5d b8 d8 22",
+		"  Data: 93.184.216.34",
+		"  Type: OPT (41)",
+		"  Option: Extended DNS Error (15)",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("DumpMessage() is missing %q:\n%s", want, dump)
+		}
+	}
+	if strings.Contains(dump, "Trailing data") {
+		t.Errorf("DumpMessage() has trailing data:\n%s", dump)
+	}
+
+	// A truncated message dumps what it can and the rest without annotations
+	dump = DumpMessage(b[:len(b)-3])
+	if !strings.Contains(dump, "Question 1: example.com.") || !strings.Contains(dump, "Trailing data") {
+		t.Errorf("DumpMessage() of a truncated message =\n%s", dump)
+	}
+}
+
+func TestQueryMessage(t *testing.T) {
+	msg := []byte{0, 0, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1}
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding} {
+		r := RequestInfo{URL: "https://dns.example/dns-query?dns=" + enc.EncodeToString(msg)}
+		if got := r.QueryMessage(); string(got) != string(msg) {
+			t.Errorf("QueryMessage() = %x, want %x", got, msg)
+		}
+	}
+	if got := (RequestInfo{URL: "https://dns.example/resolve?name=example.com"}).QueryMessage(); got != nil {
+		t.Errorf("QueryMessage() of a JSON request = %x, want nil", got)
+	}
+}
@@ -0,0 +1,167 @@
+package common
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+)
+
+// OptionExtendedError is the EDNS0 option code for Extended DNS Errors, as defined by RFC 8914
+const OptionExtendedError uint16 = 15
+
+// ExtendedError is an Extended DNS Error returned alongside the response code
+type ExtendedError struct {
+	InfoCode  int    `json:"info_code"`
+	Name      string `json:"name"`
+	ExtraText string `json:"extra_text,omitempty"`
+}
+
+// DetermineName will assign the name of the INFO-CODE as defined by:
+//     https://www.iana.org/assignments/dns-parameters/dns-parameters.xhtml#extended-dns-error-codes
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (e *ExtendedError) DetermineName() {
+	switch e.InfoCode {
+	case 0:
+		e.Name = "Other Error"
+	case 1:
+		e.Name = "Unsupported DNSKEY Algorithm"
+	case 2:
+		e.Name = "Unsupported DS Digest Type"
+	case 3:
+		e.Name = "Stale Answer"
+	case 4:
+		e.Name = "Forged Answer"
+	case 5:
+		e.Name = "DNSSEC Indeterminate"
+	case 6:
+		e.Name = "DNSSEC Bogus"
+	case 7:
+		e.Name = "Signature Expired"
+	case 8:
+		e.Name = "Signature Not Yet Valid"
+	case 9:
+		e.Name = "DNSKEY Missing"
+	case 10:
+		e.Name = "RRSIGs Missing"
+	case 11:
+		e.Name = "No Zone Key Bit Set"
+	case 12:
+		e.Name = "NSEC Missing"
+	case 13:
+		e.Name = "Cached Error"
+	case 14:
+		e.Name = "Not Ready"
+	case 15:
+		e.Name = "Blocked"
+	case 16:
+		e.Name = "Censored"
+	case 17:
+		e.Name = "Filtered"
+	case 18:
+		e.Name = "Prohibited"
+	case 19:
+		e.Name = "Stale NXDomain Answer"
+	case 20:
+		e.Name = "Not Authoritative"
+	case 21:
+		e.Name = "Not Supported"
+	case 22:
+		e.Name = "No Reachable Authority"
+	case 23:
+		e.Name = "Network Error"
+	case 24:
+		e.Name = "Invalid Data"
+	case 25:
+		e.Name = "Signature Expired Before Valid"
+	case 26:
+		e.Name = "Too Early"
+	case 27:
+		e.Name = "Unsupported NSEC3 Iterations Value"
+	case 28:
+		e.Name = "Unable To Conform To Policy"
+	case 29:
+		e.Name = "Synthesized"
+	case 30:
+		e.Name = "Invalid Query Type"
+	default:
+		e.Name = "UNASSIGNED/RESERVED"
+	}
+}
+
+// parseExtendedError decodes the data of an Extended DNS Error option
+func parseExtendedError(data []byte) (ExtendedError, bool) {
+	if len(data) < 2 {
+		return ExtendedError{}, false
+	}
+
+	e := ExtendedError{
+		InfoCode:  int(data[0])<<8 | int(data[1]),
+		ExtraText: string(data[2:]),
+	}
+	e.DetermineName()
+	return e, true
+}
+
+// Comments holds the free form comments the JSON APIs return, which some providers send as a
+// single string and others as a list of strings
+type Comments []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *Comments) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*c = Comments{s}
+		return nil
+	}
+
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*c = Comments(l)
+	return nil
+}
+
+// commentEDE matches the "EDE(6): DNSSEC Bogus (extra text)" form JSON APIs use to relay
+// Extended DNS Errors in comments. It is matched against one EDE at a time, split at
+// commentEDEStart, so that the code of the next EDE is not taken for extra text
+var (
+	commentEDE      = regexp.MustCompile(`^EDE\((\d+)\):[^(]*(?:\(([^)]*)\))?`)
+	commentEDEStart = regexp.MustCompile(`EDE\(\d+\):`)
+)
+
+// DetermineExtendedErrors will extract the Extended DNS Errors relayed in the comments of a JSON
+// response
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (q *QueryResponse) DetermineExtendedErrors() {
+	for _, c := range q.Comment {
+		starts := commentEDEStart.FindAllStringIndex(c, -1)
+		for i, start := range starts {
+			end := len(c)
+			if i+1 < len(starts) {
+				end = starts[i+1][0]
+			}
+			m := commentEDE.FindStringSubmatch(c[start[0]:end])
+			if m == nil {
+				continue
+			}
+			code, err := strconv.Atoi(m[1])
+			if err != nil {
+				continue
+			}
+			e := ExtendedError{InfoCode: code, ExtraText: m[2]}
+			e.DetermineName()
+			q.ExtendedErrors = append(q.ExtendedErrors, e)
+		}
+	}
+}
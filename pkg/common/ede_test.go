@@ -0,0 +1,36 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetermineExtendedErrors(t *testing.T) {
+	type ede struct {
+		code int
+		text string
+	}
+	for _, tt := range []struct {
+		comment Comments
+		want    []ede
+	}{
+		{Comments{"EDE(15): Blocked (policy)"}, []ede{{15, "policy"}}},
+		{Comments{"EDE(6): DNSSEC Bogus"}, []ede{{6, ""}}},
+		{Comments{"EDE(15): Blocked (x) EDE(17): Filtered (y)"}, []ede{{15, "x"}, {17, "y"}}},
+		{Comments{"EDE(15): Blocked EDE(17): Filtered (y)"}, []ede{{15, ""}, {17, "y"}}},
+		{Comments{"EDE(18): Prohibited ()"}, []ede{{18, ""}}},
+		{Comments{"Response from 192.0.2.53", "EDE(22): No Reachable Authority (at delegation example.com.)"}, []ede{{22, "at delegation example.com."}}},
+		{Comments{"Response from 192.0.2.53"}, nil},
+	} {
+		q := QueryResponse{Comment: tt.comment}
+		q.DetermineExtendedErrors()
+
+		var got []ede
+		for _, e := range q.ExtendedErrors {
+			got = append(got, ede{e.InfoCode, e.ExtraText})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DetermineExtendedErrors(%q) = %v, want %v", tt.comment, got, tt.want)
+		}
+	}
+}
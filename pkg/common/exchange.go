@@ -0,0 +1,311 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MediaTypeDNSMessage is the media type of RFC 8484 wire-format DNS messages
+const MediaTypeDNSMessage = "application/dns-message"
+
+// RequestInfo describes the request a response was received for
+type RequestInfo struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Size   int    `json:"size"`
+
+	// MessageSize is the size of the packed DNS message for wire-format queries
+	MessageSize int `json:"message_size,omitempty"`
+	Padding     int `json:"padding,omitempty"`
+
+	// Header holds the request headers as they were sent
+	Header http.Header `json:"header,omitempty"`
+
+	// Latency is the time from sending the request to decoding the response
+	Latency time.Duration `json:"latency"`
+}
+
+// ResponseInfo is the HTTP response a QueryResponse was decoded from
+type ResponseInfo struct {
+	Proto  string      `json:"proto"`
+	Status string      `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// ResponseError is returned when a response was received but could not be decoded, and carries
+// the response so that it can still be shown
+type ResponseError struct {
+	Response *ResponseInfo
+	Err      error
+}
+
+// Error implements the error interface
+func (e *ResponseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error the response could not be decoded with
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// TLSInfo describes the connection a response was received over
+type TLSInfo struct {
+	RemoteAddr   string            `json:"remote_addr"`
+	ServerName   string            `json:"server_name"`
+	Version      string            `json:"version"`
+	CipherSuite  string            `json:"cipher_suite"`
+	ALPN         string            `json:"alpn"`
+	Resumed      bool              `json:"resumed"`
+	Certificates []CertificateInfo `json:"certificates"`
+}
+
+// CertificateInfo describes a single certificate presented by the server
+type CertificateInfo struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	SPKI      string    `json:"spki_sha256"`
+}
+
+// Exchange sends a DoH request through the shared client and decodes the response, which may be
+// either JSON or an RFC 8484 wire-format message
+//
+// Arguments:
+//     ctx (context.Context): The context of the request, which cancels it once done
+//     req (*http.Request):   The request to send
+//
+// Returns:
+//     (*QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):          An error if one exists, nil otherwise
+func Exchange(ctx context.Context, req *http.Request) (*QueryResponse, error) {
+	var (
+		mu         sync.Mutex
+		remoteAddr string
+		sent       = http.Header{}
+	)
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			remoteAddr = info.Conn.RemoteAddr().String()
+		},
+		// The headers as the transport wrote them, including the ones it adds itself
+		WroteHeaderField: func(key string, value []string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !strings.HasPrefix(key, ":") {
+				sent[http.CanonicalHeaderKey(key)] = append(sent[http.CanonicalHeaderKey(key)], value...)
+			}
+		},
+	}))
+
+	start := time.Now()
+	r, err := Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending the HTTP request, err: %w", err)
+	}
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the response, err: %w", err)
+	}
+
+	info := &ResponseInfo{
+		Proto:  r.Proto,
+		Status: r.Status,
+		Header: r.Header,
+		Body:   body,
+	}
+
+	resp := new(QueryResponse)
+	switch ct := r.Header.Get("Content-Type"); {
+	case strings.HasPrefix(ct, MediaTypeDNSMessage):
+		if err := resp.UnmarshalWire(body); err != nil {
+			return nil, &ResponseError{Response: info, Err: fmt.Errorf("error unpacking the response, err: %w", err)}
+		}
+	case r.StatusCode != http.StatusOK && !strings.Contains(ct, "json") && !strings.Contains(ct, "javascript"):
+		return nil, &ResponseError{Response: info, Err: fmt.Errorf("unexpected HTTP response status: %s", r.Status)}
+	default:
+		if err := resp.Unmarshal(ioutil.NopCloser(bytes.NewReader(body))); err != nil {
+			return nil, &ResponseError{Response: info, Err: fmt.Errorf("error unmarshalling the response, err: %w", err)}
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) == 0 {
+		// Transports that do not write to the network, such as --replay, report no headers
+		sent = req.Header.Clone()
+	}
+
+	resp.DetermineStatusMessage()
+	resp.Request = &RequestInfo{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Size:    len(req.URL.String()),
+		Header:  sent,
+		Latency: time.Since(start),
+	}
+	resp.Response = info
+	resp.TLS = newTLSInfo(remoteAddr, r.TLS)
+	resp.Header = r.Header
+	return resp, nil
+}
+
+// ExchangeRaw sends a wire-format DoH request through the shared client and returns the packed
+// response message without decoding it
+//
+// Arguments:
+//     req (*http.Request): The request to send
+//
+// Returns:
+//     ([]byte): The packed DNS message, or nil if an error occurred
+//     (error):  An error if one exists, nil otherwise
+func ExchangeRaw(req *http.Request) ([]byte, error) {
+	r, err := Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending the HTTP request, err: %w", err)
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP response status: %s", r.Status)
+	}
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, MediaTypeDNSMessage) {
+		return nil, fmt.Errorf("unexpected response content type: %s", ct)
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the response, err: %w", err)
+	}
+	return b, nil
+}
+
+func newTLSInfo(remoteAddr string, cs *tls.ConnectionState) *TLSInfo {
+	if cs == nil {
+		return &TLSInfo{RemoteAddr: remoteAddr}
+	}
+
+	info := &TLSInfo{
+		RemoteAddr:  remoteAddr,
+		ServerName:  cs.ServerName,
+		Version:     tlsVersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
+		Resumed:     cs.DidResume,
+	}
+	if info.ALPN == "" {
+		info.ALPN = "http/1.1"
+	}
+	for _, cert := range cs.PeerCertificates {
+		info.Certificates = append(info.Certificates, CertificateInfo{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+			SPKI:      SPKIHash(cert.RawSubjectPublicKeyInfo),
+		})
+	}
+	return info
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", v)
+	}
+}
+
+// PrintRequest will print out the request the response was received for
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (q QueryResponse) PrintRequest() {
+	if q.Request == nil {
+		return
+	}
+
+	fmt.Printf(requestStr, q.Request.Method, q.Request.URL, q.Request.Size)
+	if q.Request.MessageSize > 0 {
+		fmt.Printf("  Message Size:       %d bytes\n", q.Request.MessageSize)
+	}
+	fmt.Printf("  Padding:            %d bytes\n", q.Request.Padding)
+	fmt.Printf("  Query Time:         %s\n", q.Request.Latency.Round(time.Millisecond))
+}
+
+// PrintTLSInfo will print out the connection the response was received over
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (q QueryResponse) PrintTLSInfo() {
+	if q.TLS == nil {
+		return
+	}
+
+	fmt.Printf(
+		tlsStr,
+		q.TLS.RemoteAddr,
+		q.TLS.ServerName,
+		q.TLS.Version,
+		q.TLS.CipherSuite,
+		q.TLS.ALPN,
+		q.TLS.Resumed)
+	for i, cert := range q.TLS.Certificates {
+		expiry := fmt.Sprintf("expires in %d days", int(time.Until(cert.NotAfter).Hours()/24))
+		if time.Now().After(cert.NotAfter) {
+			expiry = "EXPIRED"
+		}
+		fmt.Printf(
+			"    %d: %s\n       Issuer:  %s\n       Valid:   %s to %s (%s)\n       SPKI:    sha256/%s\n",
+			i,
+			cert.Subject,
+			cert.Issuer,
+			cert.NotBefore.Format(time.RFC3339),
+			cert.NotAfter.Format(time.RFC3339),
+			expiry,
+			cert.SPKI)
+	}
+}
+
+const requestStr string = `Request:
+  Method:             %s
+  URL:                %s
+  URL Size:           %d bytes
+`
+
+const tlsStr string = `TLS:
+  Remote Address:     %s
+  Server Name:        %s
+  Version:            %s
+  Cipher Suite:       %s
+  ALPN:               %s
+  Resumed:            %v
+  Certificates:
+`
@@ -0,0 +1,194 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExchangeResponseError(t *testing.T) {
+	for _, tt := range []struct {
+		name, contentType, body, want string
+		status                        int
+	}{
+		{"error page", "text/html", "<h1>Bad Gateway</h1>", "unexpected HTTP response status: 502 Bad Gateway", http.StatusBadGateway},
+		{"invalid json", "application/dns-json", `{"Status": `, "error unmarshalling the response", http.StatusOK},
+		{"invalid message", MediaTypeDNSMessage, "\x00\x01", "error unpacking the response", http.StatusOK},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tt.contentType)
+			w.Header().Set("X-Request-Id", "abc123")
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/dns-query", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := Exchange(context.Background(), req)
+		srv.Close()
+
+		if resp != nil {
+			t.Errorf("%s: Exchange() response = %+v, want nil", tt.name, resp)
+		}
+		var re *ResponseError
+		if !errors.As(err, &re) {
+			t.Fatalf("%s: Exchange() error = %v, want a *ResponseError", tt.name, err)
+		}
+		if !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%s: Exchange() error = %v, want one starting with %q", tt.name, err, tt.want)
+		}
+		if !strings.HasSuffix(re.Response.Status, http.StatusText(tt.status)) {
+			t.Errorf("%s: response status = %s, want %d", tt.name, re.Response.Status, tt.status)
+		}
+		if got := re.Response.Header.Get("X-Request-Id"); got != "abc123" {
+			t.Errorf("%s: response header X-Request-Id = %q, want abc123", tt.name, got)
+		}
+		if got := string(re.Response.Body); got != tt.body {
+			t.Errorf("%s: response body = %q, want %q", tt.name, got, tt.body)
+		}
+	}
+}
+
+func TestExchangeTLSInfo(t *testing.T) {
+	transport := Client.Transport
+	defer func() { Client.Transport = transport }()
+	if err := SetBootstrap("example.com", []string{"127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetBootstrap("example.com", nil)
+
+	for _, tt := range []struct {
+		name            string
+		http2           bool
+		config          *tls.Config
+		version, cipher string
+		alpn            string
+	}{
+		{
+			name:    "TLS 1.2 over HTTP/1.1",
+			config:  &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}},
+			version: "TLS 1.2",
+			cipher:  "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+			alpn:    "http/1.1",
+		},
+		{
+			name:    "TLS 1.3 over HTTP/2",
+			http2:   true,
+			config:  &tls.Config{MinVersion: tls.VersionTLS13},
+			version: "TLS 1.3",
+			alpn:    "h2",
+		},
+	} {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/dns-json")
+			io.WriteString(w, `{"Status": 0, "Question": [{"name": "example.com.", "type": 1}]}`)
+		}))
+		srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		srv.EnableHTTP2 = tt.http2
+		srv.TLS = tt.config
+		srv.StartTLS()
+
+		pool := x509.NewCertPool()
+		pool.AddCert(srv.Certificate())
+		tr := newTransport()
+		tr.TLSClientConfig.RootCAs = pool
+		Client.Transport = tr
+
+		_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		req, err := http.NewRequest(http.MethodGet, "https://"+net.JoinHostPort("example.com", port)+"/resolve", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := Exchange(context.Background(), req)
+		tr.CloseIdleConnections()
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: Exchange() error = %v", tt.name, err)
+		}
+
+		info := resp.TLS
+		if info.RemoteAddr != srv.Listener.Addr().String() || info.ServerName != "example.com" {
+			t.Errorf("%s: connection = %s %s, want %s example.com", tt.name, info.RemoteAddr, info.ServerName, srv.Listener.Addr())
+		}
+		if info.Version != tt.version || info.ALPN != tt.alpn || info.Resumed {
+			t.Errorf("%s: version %s, ALPN %s, resumed %v, want %s, %s, not resumed", tt.name, info.Version, info.ALPN, info.Resumed, tt.version, tt.alpn)
+		}
+		if tt.cipher != "" && info.CipherSuite != tt.cipher {
+			t.Errorf("%s: cipher suite = %s, want %s", tt.name, info.CipherSuite, tt.cipher)
+		}
+		if !strings.HasPrefix(info.CipherSuite, "TLS_") {
+			t.Errorf("%s: cipher suite = %s, want a named suite", tt.name, info.CipherSuite)
+		}
+
+		cert := srv.Certificate()
+		want := []CertificateInfo{{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+			SPKI:      SPKIHash(cert.RawSubjectPublicKeyInfo),
+		}}
+		if !reflect.DeepEqual(info.Certificates, want) {
+			t.Errorf("%s: certificates = %+v, want %+v", tt.name, info.Certificates, want)
+		}
+
+		out := captureStdout(t, resp.PrintTLSInfo)
+		for _, line := range []string{
+			"  Version:            " + tt.version + "\n",
+			"  ALPN:               " + tt.alpn + "\n",
+			"    0: " + cert.Subject.String() + "\n",
+			"       SPKI:    sha256/" + want[0].SPKI + "\n",
+		} {
+			if !strings.Contains(out, line) {
+				t.Errorf("%s: PrintTLSInfo() does not contain %q:\n%s", tt.name, line, out)
+			}
+		}
+	}
+}
+
+func TestTLSVersionName(t *testing.T) {
+	for v, want := range map[uint16]string{
+		tls.VersionTLS10: "TLS 1.0",
+		tls.VersionTLS12: "TLS 1.2",
+		tls.VersionTLS13: "TLS 1.3",
+		0x0300:           "0x0300",
+	} {
+		if got := tlsVersionName(v); got != want {
+			t.Errorf("tlsVersionName(%#04x) = %s, want %s", v, got, want)
+		}
+	}
+}
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		out <- string(b)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
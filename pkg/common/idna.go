@@ -0,0 +1,45 @@
+package common
+
+import (
+	"fmt"
+
+	"golang.org/x/net/idna"
+)
+
+// idnaProfile maps names the way a resolver would for lookups, while still
+// allowing the underscores and wildcards that appear in real DNS names
+var idnaProfile = idna.New(
+	idna.MapForLookup(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false))
+
+// ToASCII converts an internationalized domain name to its ACE (punycode) form
+//
+// Arguments:
+//     name (string): The domain name as typed by the user
+//
+// Returns:
+//     (string): The ACE form of the name
+//     (error):  An error if one exists, nil otherwise
+func ToASCII(name string) (string, error) {
+	a, err := idnaProfile.ToASCII(name)
+	if err != nil {
+		return "", fmt.Errorf("error converting %s to an ASCII domain name, err: %w", name, err)
+	}
+	return a, nil
+}
+
+// ToUnicode converts an ACE (punycode) domain name to its Unicode form
+//
+// Arguments:
+//     name (string): The domain name as returned by the provider
+//
+// Returns:
+//     (string): The Unicode form of the name, or the name unchanged if it cannot be converted
+func ToUnicode(name string) string {
+	u, err := idnaProfile.ToUnicode(name)
+	if err != nil {
+		return name
+	}
+	return u
+}
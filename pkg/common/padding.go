@@ -0,0 +1,57 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Padding policies
+const (
+	PaddingNone   = "none"
+	PaddingBlock  = "block"
+	PaddingRandom = "random"
+)
+
+// OptionPadding is the EDNS0 option code for padding, as defined by RFC 7830
+const OptionPadding uint16 = 12
+
+// PaddingBlockSize is the block length RFC 8467 recommends clients pad queries to
+const PaddingBlockSize = 128
+
+// PaddingLength returns how much padding a request of the given size needs under a policy
+//
+// Arguments:
+//     policy (string): One of PaddingNone, PaddingBlock or PaddingRandom, "" is PaddingNone
+//     size   (int):    The size of the request, including any fixed overhead the padding adds
+//
+// Returns:
+//     (int):   The number of padding octets
+//     (error): An error if the policy is unknown, nil otherwise
+func PaddingLength(policy string, size int) (int, error) {
+	switch policy {
+	case "", PaddingNone:
+		return 0, nil
+	case PaddingBlock:
+		return (PaddingBlockSize - size%PaddingBlockSize) % PaddingBlockSize, nil
+	case PaddingRandom:
+		return rand.Intn(PaddingBlockSize) + 1, nil
+	default:
+		return 0, fmt.Errorf("%s is an unsupported padding policy, expected one of: none, block, random", policy)
+	}
+}
+
+// RandomPadding returns n random characters that are safe to use unescaped in a URL
+//
+// Arguments:
+//     n (int): The number of characters
+//
+// Returns:
+//     (string): The padding
+func RandomPadding(n int) string {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = chars[rand.Intn(len(chars))]
+	}
+	return string(b)
+}
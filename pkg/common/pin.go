@@ -0,0 +1,112 @@
+package common
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// PinMismatchError is returned when none of the certificates presented by a DoH endpoint match
+// the SPKI pins configured for its host
+type PinMismatchError struct {
+	Host     string
+	Expected []string
+	Got      []string
+}
+
+// Error implements the error interface
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf(
+		"certificate pin mismatch for %s, expected one of: %s, got: %s",
+		e.Host,
+		strings.Join(e.Expected, ", "),
+		strings.Join(e.Got, ", "))
+}
+
+var pins = struct {
+	sync.RWMutex
+	hosts map[string][]string
+}{hosts: make(map[string][]string)}
+
+// SetPins sets the SPKI SHA-256 pins that a DoH endpoint's certificate chain must match
+//
+// Arguments:
+//     host   (string):   The host name of the DoH endpoint
+//     hashes ([]string): The base64 encoded SHA-256 hashes of the pinned SubjectPublicKeyInfo,
+//                        optionally prefixed with "sha256/", nil to disable pinning
+//
+// Returns:
+//     (error): An error if any of the pins is not a base64 encoded SHA-256 hash, nil otherwise
+func SetPins(host string, hashes []string) error {
+	normalized := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		h = strings.TrimPrefix(h, "sha256/")
+		b, err := base64.StdEncoding.DecodeString(h)
+		if err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("pin %s for %s is not a base64 encoded SHA-256 hash", h, host)
+		}
+		normalized = append(normalized, h)
+	}
+
+	pins.Lock()
+	defer pins.Unlock()
+	if len(normalized) == 0 {
+		delete(pins.hosts, strings.ToLower(host))
+		return nil
+	}
+	pins.hosts[strings.ToLower(host)] = normalized
+	return nil
+}
+
+// Pins returns the SPKI SHA-256 pins configured for a DoH endpoint's host
+//
+// Arguments:
+//     host (string): The host name of the DoH endpoint
+//
+// Returns:
+//     ([]string): The pins, or nil if the host is not pinned
+func Pins(host string) []string {
+	pins.RLock()
+	defer pins.RUnlock()
+	return append([]string(nil), pins.hosts[strings.ToLower(host)]...)
+}
+
+// SPKIHash returns the base64 encoded SHA-256 hash of a certificate's SubjectPublicKeyInfo
+//
+// Arguments:
+//     rawSPKI ([]byte): The DER encoded SubjectPublicKeyInfo
+//
+// Returns:
+//     (string): The pin for the public key
+func SPKIHash(rawSPKI []byte) string {
+	sum := sha256.Sum256(rawSPKI)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// verifyPins is used as the shared transport's tls.Config.VerifyConnection, it runs after the
+// normal chain verification and accepts the connection if any certificate in the chain is pinned
+func verifyPins(cs tls.ConnectionState) error {
+	expected := Pins(cs.ServerName)
+	if len(expected) == 0 {
+		return nil
+	}
+
+	got := make([]string, 0, len(cs.PeerCertificates))
+	for _, cert := range cs.PeerCertificates {
+		h := SPKIHash(cert.RawSubjectPublicKeyInfo)
+		for _, e := range expected {
+			if h == e {
+				return nil
+			}
+		}
+		got = append(got, h)
+	}
+	return &PinMismatchError{
+		Host:     cs.ServerName,
+		Expected: expected,
+		Got:      got,
+	}
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// tlsGet fetches path from the server through a new transport of the kind the shared client
+// uses, trusting the server's certificate, which is valid for example.com
+func tlsGet(t *testing.T, srv *httptest.Server, host string) error {
+	t.Helper()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	tr := newTransport()
+	tr.TLSClientConfig.RootCAs = pool
+	defer tr.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: tr}).Get("https://" + net.JoinHostPort(host, port) + "/")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func TestVerifyPins(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	if err := SetBootstrap("example.com", []string{"127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetBootstrap("example.com", nil)
+	defer SetPins("example.com", nil)
+
+	pin := SPKIHash(srv.Certificate().RawSubjectPublicKeyInfo)
+	other := SPKIHash([]byte("another key"))
+
+	// An unpinned host is only subject to the normal chain verification
+	if err := tlsGet(t, srv, "example.com"); err != nil {
+		t.Errorf("unpinned: error = %v", err)
+	}
+
+	for _, pins := range [][]string{{pin}, {other, "sha256/" + pin}} {
+		if err := SetPins("example.com", pins); err != nil {
+			t.Fatalf("SetPins() error = %v", err)
+		}
+		if err := tlsGet(t, srv, "example.com"); err != nil {
+			t.Errorf("pinned to %v: error = %v", pins, err)
+		}
+	}
+
+	if err := SetPins("example.com", []string{other}); err != nil {
+		t.Fatalf("SetPins() error = %v", err)
+	}
+	err := tlsGet(t, srv, "example.com")
+	var mismatch *PinMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("wrong pin: error = %v, want a *PinMismatchError", err)
+	}
+	want := &PinMismatchError{Host: "example.com", Expected: []string{other}, Got: []string{pin}}
+	if !reflect.DeepEqual(mismatch, want) {
+		t.Errorf("wrong pin: error = %+v, want %+v", mismatch, want)
+	}
+
+	// Pins are per host, so other hosts are not affected
+	if err := tlsGet(t, srv, "127.0.0.1"); err != nil {
+		t.Errorf("unpinned address: error = %v", err)
+	}
+}
+
+func TestSetPins(t *testing.T) {
+	defer SetPins("example.com", nil)
+
+	pin := SPKIHash([]byte("key"))
+	if err := SetPins("Example.COM", []string{"sha256/" + pin}); err != nil {
+		t.Fatalf("SetPins() error = %v", err)
+	}
+	if got := Pins("example.com"); !reflect.DeepEqual(got, []string{pin}) {
+		t.Errorf("Pins() = %v, want %v", got, []string{pin})
+	}
+
+	for _, bad := range []string{"not base64!", "c2hvcnQ="} {
+		if err := SetPins("example.com", []string{bad}); err == nil {
+			t.Errorf("SetPins(%q) error = nil", bad)
+		}
+	}
+	if got := Pins("example.com"); !reflect.DeepEqual(got, []string{pin}) {
+		t.Errorf("Pins() after an invalid pin = %v, want the previous pins", got)
+	}
+
+	if err := SetPins("example.com", nil); err != nil {
+		t.Fatalf("SetPins() error = %v", err)
+	}
+	if got := Pins("example.com"); len(got) != 0 {
+		t.Errorf("Pins() after clearing = %v, want none", got)
+	}
+}
@@ -1,31 +1,41 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // Do is a standard interface for running queries
 type Do interface {
 	Do() (*QueryResponse, error)
+	DoContext(ctx context.Context) (*QueryResponse, error)
 }
 
 // QueryResponseQuestion - Question struct for the QueryResponse struct
 type QueryResponseQuestion struct {
-	Name string `json:"name"`
-	Type int    `json:"type"`
+	Name        string `json:"name"`
+	UnicodeName string `json:"unicode_name,omitempty"`
+	Type        int    `json:"type"`
 }
 
 // QueryResponseAnswer - Answer struct for the QueryResponse struct
 type QueryResponseAnswer struct {
 	Name        string `json:"name"`
+	UnicodeName string `json:"unicode_name,omitempty"`
 	Type        int    `json:"type"`
 	TypeName    string `json:"-"`
 	TypeMeaning string `json:"-"`
 	TTL         int    `json:"TTL"`
 	Data        string `json:"data"`
+	RData       RData  `json:"rdata,omitempty"`
 }
 
 // DetermineTypeNameAndMeaning will generate the string Name and Meaning for the Provided Type
@@ -223,6 +233,12 @@ func (q *QueryResponseAnswer) DetermineTypeNameAndMeaning() {
 	case 63:
 		q.TypeName = "ZONEMD"
 		q.TypeMeaning = "Message Digest For DNS Zone"
+	case 64:
+		q.TypeName = "SVCB"
+		q.TypeMeaning = "General Purpose Service Binding"
+	case 65:
+		q.TypeName = "HTTPS"
+		q.TypeMeaning = "Service Binding For HTTPS"
 	case 99:
 		q.TypeName = "SPF"
 		q.TypeMeaning = ""
@@ -304,6 +320,93 @@ func (q *QueryResponseAnswer) DetermineTypeNameAndMeaning() {
 	}
 }
 
+// TypeFromName returns the numeric record type for a record type name such as "AAAA", a
+// numeric type such as "28", or the RFC 3597 generic form "TYPE28"
+//
+// Arguments:
+//     name (string): The record type
+//
+// Returns:
+//     (int):   The numeric record type
+//     (error): An error if the record type is unknown, nil otherwise
+func TypeFromName(name string) (int, error) {
+	upper := strings.ToUpper(name)
+	if n, err := strconv.ParseUint(strings.TrimPrefix(upper, "TYPE"), 10, 16); err == nil {
+		return int(n), nil
+	}
+
+	typeNamesOnce.Do(loadTypeNames)
+	t, ok := typeNames[upper]
+	if !ok {
+		return 0, fmt.Errorf("%s is an unknown record type", name)
+	}
+	return t, nil
+}
+
+// TypeNames returns the names of every known record type, sorted
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     ([]string): The record type names
+func TypeNames() []string {
+	typeNamesOnce.Do(loadTypeNames)
+	names := make([]string, 0, len(typeNames))
+	for name := range typeNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	typeNames     map[string]int
+	typeNamesOnce sync.Once
+)
+
+func loadTypeNames() {
+	typeNames = make(map[string]int)
+	for t := 1; t <= 260; t++ {
+		a := QueryResponseAnswer{Type: t}
+		a.DetermineTypeNameAndMeaning()
+		typeNames[a.TypeName] = t
+	}
+	for _, t := range []int{32768, 32769} {
+		a := QueryResponseAnswer{Type: t}
+		a.DetermineTypeNameAndMeaning()
+		typeNames[a.TypeName] = t
+	}
+	delete(typeNames, "UNASSIGNED/PRIVATE USE/RESERVED")
+	typeNames["ANY"] = 255
+}
+
+// RCodeFromName returns the numeric response code for a response code name such as "NXDOMAIN" or
+// a numeric response code such as "3"
+//
+// Arguments:
+//     name (string): The response code
+//
+// Returns:
+//     (int):   The numeric response code
+//     (error): An error if the response code is unknown, nil otherwise
+func RCodeFromName(name string) (int, error) {
+	if n, err := strconv.ParseUint(name, 10, 16); err == nil {
+		return int(n), nil
+	}
+
+	for code := 0; code <= 23; code++ {
+		q := QueryResponse{StatusCode: code}
+		q.DetermineStatusMessage()
+		for _, n := range strings.Split(q.StatusName, "/") {
+			if strings.EqualFold(n, name) && q.StatusName != "UNASSIGNED/RESERVED" {
+				return code, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%s is an unknown response code", name)
+}
+
 // QueryResponse is the standard response from root-level DNS providers
 type QueryResponse struct {
 	StatusCode       int                     `json:"Status"`
@@ -316,8 +419,23 @@ type QueryResponse struct {
 	CD               bool                    `json:"CD"`
 	Question         []QueryResponseQuestion `json:"Question"`
 	Answer           []QueryResponseAnswer   `json:"Answer"`
-	Additional       []interface{}           `json:"Additional"`         // Google Only
-	EDNSClientSubnet string                  `json:"edns_client_subnet"` // Google Only
+	Authority        []QueryResponseAnswer   `json:"Authority"`
+	Additional       []QueryResponseAnswer   `json:"Additional"`
+	EDNSClientSubnet string                  `json:"edns_client_subnet"`
+	ExtendedErrors   []ExtendedError         `json:"ExtendedErrors,omitempty"`
+	Comment          Comments                `json:"Comment,omitempty"`
+	Request          *RequestInfo            `json:"Request,omitempty"`
+	Response         *ResponseInfo           `json:"Response,omitempty"`
+	TLS              *TLSInfo                `json:"TLS,omitempty"`
+	ProviderInfo     map[string]string       `json:"ProviderInfo,omitempty"`
+	Blocking         *BlockingVerdict        `json:"Blocking,omitempty"`
+	Header           http.Header             `json:"-"`
+
+	// EDNSClientSubnetScope is the scope prefix length returned in a wire-format ECS option
+	EDNSClientSubnetScope *int `json:"edns_client_subnet_scope,omitempty"`
+
+	// Raw is the packed message a wire-format response was decoded from, nil for JSON responses
+	Raw []byte `json:"-"`
 }
 
 // DetermineStatusMessage will read the Status attribute and assign a message as defined by:
@@ -412,7 +530,95 @@ func (q *QueryResponse) Unmarshal(body io.ReadCloser) error {
 		return err
 	}
 
-	return json.Unmarshal(b, q)
+	if err := json.Unmarshal(b, q); err != nil {
+		return err
+	}
+
+	q.DetermineUnicodeNames()
+	q.DetermineRData()
+	q.DetermineExtendedErrors()
+	return nil
+}
+
+// DetermineRData will parse the data of every record into its typed form
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (q *QueryResponse) DetermineRData() {
+	for _, section := range [][]QueryResponseAnswer{q.Answer, q.Authority, q.Additional} {
+		for i := range section {
+			if section[i].RData != nil {
+				continue
+			}
+			// Records that cannot be parsed keep their data in presentation format only
+			section[i].RData, _ = ParseRData(section[i].Type, section[i].Data)
+		}
+	}
+}
+
+// DetermineUnicodeNames will fill in the Unicode form of every question and answer name that is
+// an internationalized domain name
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (q *QueryResponse) DetermineUnicodeNames() {
+	for i := range q.Question {
+		q.Question[i].UnicodeName = unicodeName(q.Question[i].Name)
+	}
+	for _, section := range [][]QueryResponseAnswer{q.Answer, q.Authority, q.Additional} {
+		for i := range section {
+			section[i].UnicodeName = unicodeName(section[i].Name)
+		}
+	}
+}
+
+// unicodeName returns the Unicode form of name, or "" if it is the same as name
+func unicodeName(name string) string {
+	u := ToUnicode(name)
+	if strings.EqualFold(u, name) {
+		return ""
+	}
+	return u
+}
+
+// HasAnswer reports whether any answer has the provided data, ignoring case and the trailing dot
+// of names
+//
+// Arguments:
+//     data (string): The record data to look for
+//
+// Returns:
+//     (bool): true if an answer has the data, false otherwise
+func (q QueryResponse) HasAnswer(data string) bool {
+	for _, a := range q.Answer {
+		if strings.EqualFold(strings.TrimSuffix(a.Data, "."), strings.TrimSuffix(data, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintJSON will print out the response as indented JSON
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (error): An error if one exists, nil otherwise
+func (q QueryResponse) PrintJSON() error {
+	b, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling the response, err: %w", err)
+	}
+
+	fmt.Println(string(b))
+	return nil
 }
 
 // Print will print out the answers section
@@ -432,17 +638,71 @@ func (q QueryResponse) Print() {
 		q.AD,
 		q.CD,
 		q.EDNSClientSubnet)
-	for _, i := range q.Answer {
+	if q.EDNSClientSubnetScope != nil {
+		fmt.Printf("  eDNS Scope Prefix:  /%d\n", *q.EDNSClientSubnetScope)
+	}
+	for _, e := range q.ExtendedErrors {
+		if e.ExtraText != "" {
+			fmt.Printf("  Extended Error:     %d: %s (%s)\n", e.InfoCode, e.Name, e.ExtraText)
+			continue
+		}
+		fmt.Printf("  Extended Error:     %d: %s\n", e.InfoCode, e.Name)
+	}
+	for _, c := range q.Comment {
+		fmt.Printf("  Comment:            %s\n", c)
+	}
+	fmt.Println("  Data:")
+	printRecords(q.Answer)
+	if len(q.Authority) > 0 {
+		fmt.Println("  Authority:")
+		printRecords(q.Authority)
+	}
+	if len(q.Additional) > 0 {
+		fmt.Println("  Additional:")
+		printRecords(q.Additional)
+	}
+}
+
+func printRecords(records []QueryResponseAnswer) {
+	for _, i := range records {
 		i.DetermineTypeNameAndMeaning()
+		name := i.Name
+		if i.UnicodeName != "" {
+			name = fmt.Sprintf("%s (%s)", i.Name, i.UnicodeName)
+		}
 		fmt.Printf(
 			"    %s\t%d\t%s\t%s\n",
-			i.Name,
+			name,
 			i.TTL,
 			i.TypeName,
 			i.Data)
 	}
 }
 
+// PrintProviderInfo will print out the provider specific information about the response
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (q QueryResponse) PrintProviderInfo() {
+	if len(q.ProviderInfo) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(q.ProviderInfo))
+	for k := range q.ProviderInfo {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Println("Provider Info:")
+	for _, k := range keys {
+		fmt.Printf("  %-19s %s\n", k+":", q.ProviderInfo[k])
+	}
+}
+
 const answerStr string = `Answer:
   Status:             %s: %s
   Truncated:          %v
@@ -451,5 +711,4 @@ const answerStr string = `Answer:
   DNSSEC Validated:   %v
   DNSSEC Disabled:    %v
   eDNS Client Subnet: %s
-  Data:
 `
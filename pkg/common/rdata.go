@@ -0,0 +1,410 @@
+package common
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// RData is implemented by the typed forms of a record's data
+type RData interface {
+	// String returns the presentation format of the record data
+	String() string
+}
+
+// AData is the data of an A record
+type AData struct {
+	Address net.IP `json:"address"`
+}
+
+func (r AData) String() string { return r.Address.String() }
+
+// AAAAData is the data of an AAAA record
+type AAAAData struct {
+	Address net.IP `json:"address"`
+}
+
+func (r AAAAData) String() string { return r.Address.String() }
+
+// NSData is the data of an NS record
+type NSData struct {
+	Host string `json:"host"`
+}
+
+func (r NSData) String() string { return r.Host }
+
+// CNAMEData is the data of a CNAME record
+type CNAMEData struct {
+	Target string `json:"target"`
+}
+
+func (r CNAMEData) String() string { return r.Target }
+
+// DNAMEData is the data of a DNAME record
+type DNAMEData struct {
+	Target string `json:"target"`
+}
+
+func (r DNAMEData) String() string { return r.Target }
+
+// PTRData is the data of a PTR record
+type PTRData struct {
+	Host string `json:"host"`
+}
+
+func (r PTRData) String() string { return r.Host }
+
+// MXData is the data of an MX record
+type MXData struct {
+	Preference int    `json:"preference"`
+	Host       string `json:"host"`
+}
+
+func (r MXData) String() string { return fmt.Sprintf("%d %s", r.Preference, r.Host) }
+
+// SOAData is the data of an SOA record
+type SOAData struct {
+	MName   string `json:"mname"`
+	RName   string `json:"rname"`
+	Serial  uint32 `json:"serial"`
+	Refresh uint32 `json:"refresh"`
+	Retry   uint32 `json:"retry"`
+	Expire  uint32 `json:"expire"`
+	Minimum uint32 `json:"minimum"`
+}
+
+func (r SOAData) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", r.MName, r.RName, r.Serial, r.Refresh, r.Retry, r.Expire, r.Minimum)
+}
+
+// TXTData is the data of a TXT record
+type TXTData struct {
+	Text []string `json:"text"`
+}
+
+func (r TXTData) String() string {
+	s := make([]string, 0, len(r.Text))
+	for _, t := range r.Text {
+		s = append(s, strconv.Quote(t))
+	}
+	return strings.Join(s, " ")
+}
+
+// Joined returns the character strings of the record concatenated, which is how SPF, DKIM and
+// DMARC records split over several strings are meant to be read
+func (r TXTData) Joined() string { return strings.Join(r.Text, "") }
+
+// SRVData is the data of an SRV record
+type SRVData struct {
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Port     int    `json:"port"`
+	Target   string `json:"target"`
+}
+
+func (r SRVData) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target)
+}
+
+// CAAData is the data of a CAA record
+type CAAData struct {
+	Flags int    `json:"flags"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+func (r CAAData) String() string { return fmt.Sprintf("%d %s %s", r.Flags, r.Tag, strconv.Quote(r.Value)) }
+
+// ParseRData parses the presentation format of a record's data, as returned in the data field of
+// the JSON APIs, into its typed form
+//
+// Arguments:
+//     t    (int):    The record type
+//     data (string): The presentation format of the data
+//
+// Returns:
+//     (RData): The typed record data, or nil if the type is not supported
+//     (error): An error if the data could not be parsed, nil otherwise
+func ParseRData(t int, data string) (RData, error) {
+	// RFC 3597 generic encoding, which some providers use for types they do not know
+	if strings.HasPrefix(data, `\# `) {
+		b, err := genericRData(data)
+		if err != nil {
+			return nil, err
+		}
+		return parseRDataBytes(t, b)
+	}
+
+	fields := strings.Fields(data)
+	switch t {
+	case 1, 28:
+		ip := net.ParseIP(data)
+		if ip == nil {
+			return nil, fmt.Errorf("%s is not an IP address", data)
+		}
+		if t == 1 {
+			return AData{Address: ip}, nil
+		}
+		return AAAAData{Address: ip}, nil
+	case 2:
+		return NSData{Host: data}, nil
+	case 5:
+		return CNAMEData{Target: data}, nil
+	case 12:
+		return PTRData{Host: data}, nil
+	case 39:
+		return DNAMEData{Target: data}, nil
+	case 15:
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s is not valid MX data", data)
+		}
+		pref, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s is not valid MX data", data)
+		}
+		return MXData{Preference: pref, Host: fields[1]}, nil
+	case 6:
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("%s is not valid SOA data", data)
+		}
+		var n [5]uint32
+		for i := range n {
+			v, err := strconv.ParseUint(fields[i+2], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not valid SOA data", data)
+			}
+			n[i] = uint32(v)
+		}
+		return SOAData{
+			MName:   fields[0],
+			RName:   fields[1],
+			Serial:  n[0],
+			Refresh: n[1],
+			Retry:   n[2],
+			Expire:  n[3],
+			Minimum: n[4],
+		}, nil
+	case 16, 99:
+		return TXTData{Text: characterStrings(data)}, nil
+	case 33:
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("%s is not valid SRV data", data)
+		}
+		var n [3]int
+		for i := range n {
+			v, err := strconv.Atoi(fields[i])
+			if err != nil {
+				return nil, fmt.Errorf("%s is not valid SRV data", data)
+			}
+			n[i] = v
+		}
+		return SRVData{Priority: n[0], Weight: n[1], Port: n[2], Target: fields[3]}, nil
+	case 257:
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s is not valid CAA data", data)
+		}
+		flags, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s is not valid CAA data", data)
+		}
+		value := strings.TrimSpace(strings.SplitN(data, fields[1], 2)[1])
+		return CAAData{Flags: flags, Tag: fields[1], Value: strings.Join(characterStrings(value), "")}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// characterStrings splits presentation format character strings, which may or may not be quoted
+func characterStrings(data string) []string {
+	if !strings.HasPrefix(data, `"`) {
+		return []string{data}
+	}
+
+	var (
+		out     []string
+		current strings.Builder
+		quoted  bool
+	)
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			current.WriteByte(data[i])
+		case c == '"':
+			if quoted {
+				out = append(out, current.String())
+				current.Reset()
+			}
+			quoted = !quoted
+		case quoted:
+			current.WriteByte(c)
+		}
+	}
+	if quoted {
+		out = append(out, current.String())
+	}
+	return out
+}
+
+// genericRData decodes the RFC 3597 "\# length hex" format
+func genericRData(data string) ([]byte, error) {
+	fields := strings.Fields(data)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("%s is not valid generic record data", data)
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid generic record data", data)
+	}
+	b, err := hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil || len(b) != n {
+		return nil, fmt.Errorf("%s is not valid generic record data", data)
+	}
+	return b, nil
+}
+
+// parseRDataBytes parses the wire format of the record types that dnsmessage does not understand
+func parseRDataBytes(t int, b []byte) (RData, error) {
+	switch t {
+	case 257:
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, fmt.Errorf("invalid CAA data")
+		}
+		return CAAData{
+			Flags: int(b[0]),
+			Tag:   string(b[2 : 2+int(b[1])]),
+			Value: string(b[2+int(b[1]):]),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// rdataFromBody converts a parsed wire-format resource body into its typed form
+func rdataFromBody(t int, body dnsmessage.ResourceBody) RData {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return AData{Address: net.IP(append([]byte(nil), b.A[:]...))}
+	case *dnsmessage.AAAAResource:
+		return AAAAData{Address: net.IP(append([]byte(nil), b.AAAA[:]...))}
+	case *dnsmessage.NSResource:
+		return NSData{Host: b.NS.String()}
+	case *dnsmessage.CNAMEResource:
+		return CNAMEData{Target: b.CNAME.String()}
+	case *dnsmessage.PTRResource:
+		return PTRData{Host: b.PTR.String()}
+	case *dnsmessage.MXResource:
+		return MXData{Preference: int(b.Pref), Host: b.MX.String()}
+	case *dnsmessage.SOAResource:
+		return SOAData{
+			MName:   b.NS.String(),
+			RName:   b.MBox.String(),
+			Serial:  b.Serial,
+			Refresh: b.Refresh,
+			Retry:   b.Retry,
+			Expire:  b.Expire,
+			Minimum: b.MinTTL,
+		}
+	case *dnsmessage.TXTResource:
+		return TXTData{Text: append([]string(nil), b.TXT...)}
+	case *dnsmessage.SRVResource:
+		return SRVData{Priority: int(b.Priority), Weight: int(b.Weight), Port: int(b.Port), Target: b.Target.String()}
+	case *dnsmessage.UnknownResource:
+		r, _ := parseRDataBytes(t, b.Data)
+		return r
+	default:
+		return nil
+	}
+}
+
+// ResourceBody converts typed record data into a resource body that can be packed into a
+// wire-format message
+//
+// Arguments:
+//     t     (int):    The record type
+//     rdata (RData):  The typed record data, or nil for types that have no typed form
+//     data  (string): The presentation format of the data, used when rdata is nil and it is in
+//                     the RFC 3597 generic format
+//
+// Returns:
+//     (dnsmessage.ResourceBody): The resource body
+//     (error):                   An error if the data cannot be encoded, nil otherwise
+func ResourceBody(t int, rdata RData, data string) (dnsmessage.ResourceBody, error) {
+	name := func(s string) (dnsmessage.Name, error) { return dnsmessage.NewName(FQDN(s)) }
+	switch d := rdata.(type) {
+	case AData:
+		var a [4]byte
+		copy(a[:], d.Address.To4())
+		return &dnsmessage.AResource{A: a}, nil
+	case AAAAData:
+		var a [16]byte
+		copy(a[:], d.Address.To16())
+		return &dnsmessage.AAAAResource{AAAA: a}, nil
+	case NSData:
+		n, err := name(d.Host)
+		return &dnsmessage.NSResource{NS: n}, err
+	case CNAMEData:
+		n, err := name(d.Target)
+		return &dnsmessage.CNAMEResource{CNAME: n}, err
+	case PTRData:
+		n, err := name(d.Host)
+		return &dnsmessage.PTRResource{PTR: n}, err
+	case MXData:
+		n, err := name(d.Host)
+		return &dnsmessage.MXResource{Pref: uint16(d.Preference), MX: n}, err
+	case TXTData:
+		return &dnsmessage.TXTResource{TXT: d.Text}, nil
+	case SRVData:
+		n, err := name(d.Target)
+		return &dnsmessage.SRVResource{Priority: uint16(d.Priority), Weight: uint16(d.Weight), Port: uint16(d.Port), Target: n}, err
+	case SOAData:
+		ns, err := name(d.MName)
+		if err != nil {
+			return nil, err
+		}
+		mbox, err := name(d.RName)
+		return &dnsmessage.SOAResource{
+			NS:      ns,
+			MBox:    mbox,
+			Serial:  d.Serial,
+			Refresh: d.Refresh,
+			Retry:   d.Retry,
+			Expire:  d.Expire,
+			MinTTL:  d.Minimum,
+		}, err
+	case DNAMEData:
+		b, err := packName(d.Target)
+		return &dnsmessage.UnknownResource{Type: dnsmessage.Type(t), Data: b}, err
+	case CAAData:
+		b := append([]byte{byte(d.Flags), byte(len(d.Tag))}, d.Tag...)
+		return &dnsmessage.UnknownResource{Type: dnsmessage.Type(t), Data: append(b, d.Value...)}, nil
+	}
+
+	if strings.HasPrefix(data, `\# `) {
+		b, err := genericRData(data)
+		return &dnsmessage.UnknownResource{Type: dnsmessage.Type(t), Data: b}, err
+	}
+	return nil, fmt.Errorf("record type %d cannot be encoded from %s", t, data)
+}
+
+// packName encodes a domain name as uncompressed labels, as the record types that dnsmessage does
+// not understand carry them
+func packName(name string) ([]byte, error) {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		if len(label) > 63 {
+			return nil, fmt.Errorf("%s has a label longer than 63 octets", name)
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0), nil
+}
@@ -0,0 +1,307 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Recording is the file format of --record and --replay, the HTTP exchanges of a run in the
+// order they were made
+type Recording struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded HTTP request and the response it received
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded HTTP request
+type RecordedRequest struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   RecordedBody `json:"body,omitempty"`
+}
+
+// RecordedResponse is a recorded HTTP response
+type RecordedResponse struct {
+	StatusCode int          `json:"status_code"`
+	Status     string       `json:"status"`
+	Header     http.Header  `json:"header"`
+	Body       RecordedBody `json:"body"`
+}
+
+// RecordedBody is an HTTP body, kept as text when it is printable so that JSON responses stay
+// readable in the file, and as base64 otherwise
+type RecordedBody []byte
+
+// MarshalJSON implements the json.Marshaler interface
+func (b RecordedBody) MarshalJSON() ([]byte, error) {
+	if isText(b) {
+		return json.Marshal(map[string]string{"text": string(b)})
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, c := range b {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (b *RecordedBody) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Text   *string `json:"text"`
+		Base64 *string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch {
+	case v.Text != nil:
+		*b = RecordedBody(*v.Text)
+	case v.Base64 != nil:
+		raw, err := base64.StdEncoding.DecodeString(*v.Base64)
+		if err != nil {
+			return fmt.Errorf("error decoding the recorded body, err: %w", err)
+		}
+		*b = raw
+	default:
+		*b = nil
+	}
+	return nil
+}
+
+// Record wraps the shared client's transport so that every exchange it makes is saved to path.
+// The file is rewritten after each exchange, so it is complete even when the run exits early
+//
+// Arguments:
+//     path (string): The file to save the recording to
+//
+// Returns:
+//     (error): An error if the file cannot be created, nil otherwise
+func Record(path string) error {
+	r := &recorder{path: path, next: Client.Transport}
+	if r.next == nil {
+		r.next = http.DefaultTransport
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+	Client.Transport = r
+	return nil
+}
+
+// Replay replaces the shared client's transport with one that answers requests with the
+// responses saved by Record, without touching the network
+//
+// Arguments:
+//     path (string): The file the recording was saved to
+//
+// Returns:
+//     (error): An error if the file cannot be read, nil otherwise
+func Replay(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading the recording, err: %w", err)
+	}
+
+	var rec Recording
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return fmt.Errorf("error parsing the recording %s, err: %w", path, err)
+	}
+
+	Client.Transport = &replayer{
+		path:         path,
+		interactions: rec.Interactions,
+		used:         make([]bool, len(rec.Interactions)),
+	}
+	return nil
+}
+
+type recorder struct {
+	path string
+	next http.RoundTripper
+
+	mu        sync.Mutex
+	recording Recording
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Interactions = append(r.recording.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header,
+			Body:   reqBody,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header,
+			Body:       respBody,
+		},
+	})
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *recorder) save() error {
+	b, err := json.MarshalIndent(r.recording, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding the recording, err: %w", err)
+	}
+	// The recording holds every name queried and the headers exchanged, so it is only readable
+	// by the user
+	if err := ioutil.WriteFile(r.path, append(b, '\n'), 0600); err != nil {
+		return fmt.Errorf("error saving the recording, err: %w", err)
+	}
+	return nil
+}
+
+type replayer struct {
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// RoundTrip answers with the first unused recorded response to an equivalent request, so that
+// repeated and concurrent queries are served in the order they were recorded
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	key := requestKey(req.Method, req.URL, body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if r.used[i] {
+			continue
+		}
+		u, err := url.Parse(in.Request.URL)
+		if err != nil || requestKey(in.Request.Method, u, in.Request.Body) != key {
+			continue
+		}
+
+		r.used[i] = true
+		return &http.Response{
+			Status:        in.Response.Status,
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header,
+			Body:          ioutil.NopCloser(bytes.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response to %s %s in %s", req.Method, req.URL, r.path)
+}
+
+// requestKey identifies a request regardless of its padding, which is random, and its DNS
+// message ID, so that a replayed query matches the recorded one
+func requestKey(method string, u *url.URL, body []byte) string {
+	v := u.Query()
+	v.Del("random_padding")
+	if dns := v.Get("dns"); dns != "" {
+		if msg, err := base64.RawURLEncoding.DecodeString(dns); err == nil {
+			v.Set("dns", base64.RawURLEncoding.EncodeToString(normalizeMessage(msg)))
+		}
+	}
+	if len(body) > 0 {
+		body = normalizeMessage(body)
+	}
+	return strings.Join([]string{
+		method,
+		strings.ToLower(u.Host),
+		u.EscapedPath(),
+		v.Encode(),
+		base64.StdEncoding.EncodeToString(body),
+	}, " ")
+}
+
+// normalizeMessage strips the ID and padding option of a packed DNS message, returning it
+// unchanged when it is not one
+func normalizeMessage(b []byte) []byte {
+	var m dnsmessage.Message
+	if err := m.Unpack(b); err != nil {
+		return b
+	}
+
+	m.Header.ID = 0
+	for i, rr := range m.Additionals {
+		opt, ok := rr.Body.(*dnsmessage.OPTResource)
+		if !ok {
+			continue
+		}
+		stripped := &dnsmessage.OPTResource{}
+		for _, o := range opt.Options {
+			if o.Code != OptionPadding {
+				stripped.Options = append(stripped.Options, o)
+			}
+		}
+		m.Additionals[i].Body = stripped
+	}
+
+	out, err := m.Pack()
+	if err != nil {
+		return b
+	}
+	return out
+}
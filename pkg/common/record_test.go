@@ -0,0 +1,79 @@
+package common_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"github.com/j4ng5y/dohdig/pkg/google"
+	"github.com/j4ng5y/dohdig/pkg/wire"
+)
+
+func TestRecordReplay(t *testing.T) {
+	transport := common.Client.Transport
+	defer func() { common.Client.Transport = transport }()
+	path := filepath.Join(t.TempDir(), "recording.json")
+
+	jsonQuery := google.QueryRequest{Resource: "example.com", ResourceType: "A", Padding: common.PaddingRandom}
+	wireQuery := wire.QueryRequest{
+		URL:          "https://doh.example.net/dns-query",
+		Resource:     "example.com",
+		ResourceType: "MX",
+		Padding:      common.PaddingRandom,
+	}
+
+	s := dohtest.NewServer()
+	s.Handle("example.com", 1, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "93.184.216.34"}},
+	})
+	s.Handle("example.com", 15, dohtest.Answer{
+		AD:      true,
+		Records: []dohtest.Record{{Name: "example.com.", Type: 15, TTL: 300, Data: "10 mail.example.com."}},
+	})
+	if err := common.Record(path); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if _, err := jsonQuery.Do(); err != nil {
+		t.Fatalf("recorded JSON query error = %v", err)
+	}
+	if _, err := wireQuery.Do(); err != nil {
+		t.Fatalf("recorded wire query error = %v", err)
+	}
+	s.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0600 {
+		t.Errorf("recording mode = %o, want 600", mode)
+	}
+
+	// The server is gone, so only the recording can answer, even though the padding differs
+	if err := common.Replay(path); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	resp, err := jsonQuery.Do()
+	if err != nil {
+		t.Fatalf("replayed JSON query error = %v", err)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].Data != "93.184.216.34" {
+		t.Errorf("replayed JSON answer = %+v, want a single 93.184.216.34 record", resp.Answer)
+	}
+
+	resp, err = wireQuery.Do()
+	if err != nil {
+		t.Fatalf("replayed wire query error = %v", err)
+	}
+	if !resp.AD || len(resp.Answer) != 1 || resp.Answer[0].Data != "10 mail.example.com." {
+		t.Errorf("replayed wire response = %+v, want AD with a single MX record", resp)
+	}
+
+	// Each recorded exchange is served once
+	if _, err := jsonQuery.Do(); err == nil {
+		t.Error("repeated JSON query error = nil, want no recorded response")
+	}
+}
@@ -0,0 +1,280 @@
+package common
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// OptionClientSubnet is the EDNS0 option code for EDNS Client Subnet, as defined by RFC 7871
+const OptionClientSubnet uint16 = 8
+
+// UnmarshalWire parses an RFC 1035 wire-format DNS message into this QueryResponse
+//
+// Arguments:
+//     b ([]byte): The packed DNS message
+//
+// Returns:
+//     (error): An error if one exists, nil otherwise
+func (q *QueryResponse) UnmarshalWire(b []byte) error {
+	var p dnsmessage.Parser
+	h, err := p.Start(b)
+	if err != nil {
+		return err
+	}
+
+	q.StatusCode = int(h.RCode)
+	q.TC = h.Truncated
+	q.RD = h.RecursionDesired
+	q.RA = h.RecursionAvailable
+	q.AD = h.AuthenticData
+	q.CD = h.CheckingDisabled
+
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return err
+	}
+	for _, question := range questions {
+		q.Question = append(q.Question, QueryResponseQuestion{
+			Name: question.Name.String(),
+			Type: int(question.Type),
+		})
+	}
+
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return err
+	}
+	for _, rr := range answers {
+		q.Answer = append(q.Answer, wireAnswer(rr))
+	}
+
+	authorities, err := p.AllAuthorities()
+	if err != nil {
+		return err
+	}
+	for _, rr := range authorities {
+		q.Authority = append(q.Authority, wireAnswer(rr))
+	}
+
+	additionals, err := p.AllAdditionals()
+	if err != nil {
+		return err
+	}
+	for _, rr := range additionals {
+		opt, ok := rr.Body.(*dnsmessage.OPTResource)
+		if !ok {
+			q.Additional = append(q.Additional, wireAnswer(rr))
+			continue
+		}
+
+		q.StatusCode = int(rr.Header.ExtendedRCode(h.RCode))
+		for _, o := range opt.Options {
+			switch o.Code {
+			case OptionClientSubnet:
+				q.parseClientSubnet(o.Data)
+			case OptionExtendedError:
+				if e, ok := parseExtendedError(o.Data); ok {
+					q.ExtendedErrors = append(q.ExtendedErrors, e)
+				}
+			}
+		}
+	}
+
+	q.DetermineUnicodeNames()
+	q.Raw = b
+	return nil
+}
+
+// MarshalWire packs this QueryResponse into an RFC 1035 wire-format message, which is the message
+// it was decoded from for wire-format responses and is built from the decoded fields otherwise.
+// Records whose data cannot be encoded are left out of a built message
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     ([]byte): The packed DNS message
+//     (error):  An error if one exists, nil otherwise
+func (q QueryResponse) MarshalWire() ([]byte, error) {
+	if q.Raw != nil {
+		return q.Raw, nil
+	}
+
+	m := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			Response:           true,
+			Truncated:          q.TC,
+			RecursionDesired:   q.RD,
+			RecursionAvailable: q.RA,
+			AuthenticData:      q.AD,
+			CheckingDisabled:   q.CD,
+			RCode:              dnsmessage.RCode(q.StatusCode & 0x0f),
+		},
+	}
+	for _, question := range q.Question {
+		name, err := dnsmessage.NewName(FQDN(question.Name))
+		if err != nil {
+			return nil, fmt.Errorf("error building the question name: %s, err: %w", question.Name, err)
+		}
+		m.Questions = append(m.Questions, dnsmessage.Question{
+			Name:  name,
+			Type:  dnsmessage.Type(question.Type),
+			Class: dnsmessage.ClassINET,
+		})
+	}
+
+	var err error
+	if m.Answers, err = wireResources(q.Answer); err != nil {
+		return nil, err
+	}
+	if m.Authorities, err = wireResources(q.Authority); err != nil {
+		return nil, err
+	}
+	if m.Additionals, err = wireResources(q.Additional); err != nil {
+		return nil, err
+	}
+
+	// Extended response codes and errors can only be carried in an OPT record
+	if q.StatusCode > 0x0f || len(q.ExtendedErrors) > 0 {
+		opt := &dnsmessage.OPTResource{}
+		for _, e := range q.ExtendedErrors {
+			opt.Options = append(opt.Options, dnsmessage.Option{
+				Code: OptionExtendedError,
+				Data: append([]byte{byte(e.InfoCode >> 8), byte(e.InfoCode)}, e.ExtraText...),
+			})
+		}
+		var h dnsmessage.ResourceHeader
+		if err := h.SetEDNS0(4096, dnsmessage.RCode(q.StatusCode), false); err != nil {
+			return nil, err
+		}
+		m.Additionals = append(m.Additionals, dnsmessage.Resource{Header: h, Body: opt})
+	}
+	return m.Pack()
+}
+
+// wireResources converts decoded records back into resources, skipping the ones whose data
+// cannot be encoded
+func wireResources(answers []QueryResponseAnswer) ([]dnsmessage.Resource, error) {
+	var out []dnsmessage.Resource
+	for _, a := range answers {
+		rdata := a.RData
+		if rdata == nil {
+			r, err := ParseRData(a.Type, a.Data)
+			if err != nil {
+				continue
+			}
+			rdata = r
+		}
+		body, err := ResourceBody(a.Type, rdata, a.Data)
+		if err != nil {
+			continue
+		}
+
+		name, err := dnsmessage.NewName(FQDN(a.Name))
+		if err != nil {
+			return nil, fmt.Errorf("error building the record name: %s, err: %w", a.Name, err)
+		}
+		out = append(out, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{
+				Name:  name,
+				Type:  dnsmessage.Type(a.Type),
+				Class: dnsmessage.ClassINET,
+				TTL:   uint32(a.TTL),
+			},
+			Body: body,
+		})
+	}
+	return out, nil
+}
+
+// FQDN returns name with a trailing dot, the fully qualified form DNS messages and the net
+// package use
+//
+// Arguments:
+//     name (string): The name, with or without a trailing dot
+//
+// Returns:
+//     (string): The fully qualified name
+func FQDN(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// parseClientSubnet fills in the EDNS Client Subnet that the server echoed back
+func (q *QueryResponse) parseClientSubnet(data []byte) {
+	if len(data) < 4 {
+		return
+	}
+
+	family := int(data[0])<<8 | int(data[1])
+	source := int(data[2])
+	scope := int(data[3])
+	addr := make(net.IP, net.IPv6len)
+	if family == 1 {
+		addr = make(net.IP, net.IPv4len)
+	}
+	copy(addr, data[4:])
+
+	q.EDNSClientSubnet = fmt.Sprintf("%s/%d", addr, source)
+	q.EDNSClientSubnetScope = &scope
+}
+
+func wireAnswer(rr dnsmessage.Resource) QueryResponseAnswer {
+	a := QueryResponseAnswer{
+		Name:  rr.Header.Name.String(),
+		Type:  int(rr.Header.Type),
+		TTL:   int(rr.Header.TTL),
+		Data:  RDataString(rr.Body),
+		RData: rdataFromBody(int(rr.Header.Type), rr.Body),
+	}
+	if _, ok := rr.Body.(*dnsmessage.UnknownResource); ok && a.RData != nil {
+		a.Data = a.RData.String()
+	}
+	return a
+}
+
+// RDataString formats the RDATA of a resource the same way the JSON APIs present it in the
+// data field, falling back to the RFC 3597 generic format for types that are not understood
+//
+// Arguments:
+//     body (dnsmessage.ResourceBody): The parsed resource body
+//
+// Returns:
+//     (string): The presentation format of the RDATA
+func RDataString(body dnsmessage.ResourceBody) string {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(b.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(b.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return b.CNAME.String()
+	case *dnsmessage.NSResource:
+		return b.NS.String()
+	case *dnsmessage.PTRResource:
+		return b.PTR.String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", b.Pref, b.MX)
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d %d %d %d %d", b.NS, b.MBox, b.Serial, b.Refresh, b.Retry, b.Expire, b.MinTTL)
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", b.Priority, b.Weight, b.Port, b.Target)
+	case *dnsmessage.TXTResource:
+		s := make([]string, 0, len(b.TXT))
+		for _, t := range b.TXT {
+			s = append(s, strconv.Quote(t))
+		}
+		return strings.Join(s, " ")
+	case *dnsmessage.UnknownResource:
+		return fmt.Sprintf("\\# %d %s", len(b.Data), hex.EncodeToString(b.Data))
+	default:
+		return fmt.Sprint(body)
+	}
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestMarshalWire(t *testing.T) {
+	resp := new(QueryResponse)
+	err := resp.Unmarshal(ioutil.NopCloser(strings.NewReader(`{
+		"Status": 3, "RD": true, "RA": true, "AD": true,
+		"Question": [{"name": "www.example.com.", "type": 1}],
+		"Answer": [
+			{"name": "www.example.com.", "type": 5, "TTL": 60, "data": "example.com."},
+			{"name": "example.com.", "type": 257, "TTL": 60, "data": "0 issue \"letsencrypt.org\""},
+			{"name": "example.com.", "type": 65, "TTL": 60, "data": "1 . alpn=h2"}
+		],
+		"Authority": [{"name": "example.com.", "type": 6, "TTL": 60, "data": "ns.example.com. hostmaster.example.com. 7 7200 3600 1209600 60"}],
+		"Comment": ["EDE(15): Blocked (policy)"]
+	}`)))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	b, err := resp.MarshalWire()
+	if err != nil {
+		t.Fatalf("MarshalWire() error = %v", err)
+	}
+	got := new(QueryResponse)
+	if err := got.UnmarshalWire(b); err != nil {
+		t.Fatalf("UnmarshalWire() error = %v", err)
+	}
+
+	if got.StatusCode != 3 || !got.RD || !got.RA || !got.AD || got.CD {
+		t.Errorf("header = %d RD=%v RA=%v AD=%v CD=%v, want 3 with RD, RA and AD", got.StatusCode, got.RD, got.RA, got.AD, got.CD)
+	}
+	if len(got.Question) != 1 || got.Question[0].Name != "www.example.com." || got.Question[0].Type != 1 {
+		t.Errorf("question = %+v, want www.example.com. A", got.Question)
+	}
+
+	// The HTTPS record has no typed form, so it is left out
+	if len(got.Answer) != 2 {
+		t.Fatalf("answer = %+v, want the CNAME and CAA records", got.Answer)
+	}
+	if got.Answer[0].Data != "example.com." || got.Answer[1].Data != `0 issue "letsencrypt.org"` {
+		t.Errorf("answer data = %q, %q", got.Answer[0].Data, got.Answer[1].Data)
+	}
+	if soa, ok := got.Authority[0].RData.(SOAData); !ok || soa.Serial != 7 {
+		t.Errorf("authority = %+v, want the SOA record", got.Authority)
+	}
+	if len(got.ExtendedErrors) != 1 || got.ExtendedErrors[0].InfoCode != 15 {
+		t.Errorf("extended errors = %+v, want EDE 15", got.ExtendedErrors)
+	}
+
+	// A decoded wire-format response packs back to the message it came from
+	again, err := got.MarshalWire()
+	if err != nil || string(again) != string(b) {
+		t.Errorf("MarshalWire() of a wire-format response = %x, %v, want %x", again, err, b)
+	}
+}
+
+func TestFQDN(t *testing.T) {
+	for name, want := range map[string]string{
+		"example.com":  "example.com.",
+		"example.com.": "example.com.",
+		".":            ".",
+		"":             ".",
+	} {
+		if got := FQDN(name); got != want {
+			t.Errorf("FQDN(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
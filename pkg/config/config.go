@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Options maps command line flag names to the values they should default to
+type Options map[string]interface{}
+
+// Provider is a user defined DoH endpoint
+type Provider struct {
+	Description string   `yaml:"description"`
+	Location    string   `yaml:"location"`
+	URL         string   `yaml:"url"`
+	Bootstrap   []string `yaml:"bootstrap"`
+	Pins        []string `yaml:"pins"`
+	Format      string   `yaml:"format"`
+}
+
+// Probe is a query the exporter runs on a schedule, along with the answer it should return
+type Probe struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Provider string `yaml:"provider"`
+	Expect   string `yaml:"expect"`
+}
+
+// Config is the contents of the dohdig configuration file
+type Config struct {
+	Profile   string              `yaml:"profile"`
+	Defaults  Options             `yaml:"defaults"`
+	Profiles  map[string]Options  `yaml:"profiles"`
+	Providers map[string]Provider `yaml:"providers"`
+	Probes    []Probe             `yaml:"probes"`
+}
+
+// DefaultPath returns the location of the configuration file under the XDG config directory
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (string): The path of the configuration file
+//     (error):  An error if one exists, nil otherwise
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error locating the user config directory, err: %w", err)
+	}
+	return filepath.Join(dir, "dohdig", "config.yaml"), nil
+}
+
+// Load reads and parses the configuration file at the provided path
+//
+// Arguments:
+//     path (string): The path of the configuration file
+//
+// Returns:
+//     (*Config): A pointer to the parsed configuration, or nil if an error occurred
+//     (error):   An error if one exists, nil otherwise
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(Config)
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("error parsing the config file: %s, err: %w", path, err)
+	}
+
+	for name, p := range c.Providers {
+		if p.URL == "" {
+			return nil, fmt.Errorf("provider %s in %s has no url", name, path)
+		}
+		if p.Format != "" && p.Format != "json" && p.Format != "wire" {
+			return nil, fmt.Errorf("provider %s in %s has an unsupported format %s, expected json or wire", name, path, p.Format)
+		}
+	}
+	for i, p := range c.Probes {
+		if p.Name == "" {
+			return nil, fmt.Errorf("probe %d in %s has no name", i, path)
+		}
+	}
+	return c, nil
+}
+
+// Options merges the defaults with the named profile, with the profile taking precedence
+//
+// Arguments:
+//     profile (string): The profile to apply, or "" to use the profile named in the file
+//
+// Returns:
+//     (map[string]string): The flag values keyed by flag name
+//     (error):             An error if the profile does not exist, nil otherwise
+func (c *Config) Options(profile string) (map[string]string, error) {
+	if profile == "" {
+		profile = c.Profile
+	}
+
+	opts := make(map[string]string)
+	for k, v := range c.Defaults {
+		opts[k] = flagValue(v)
+	}
+	if profile == "" {
+		return opts, nil
+	}
+
+	p, ok := c.Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %s is not defined, available profiles: %s", profile, strings.Join(c.ProfileNames(), ", "))
+	}
+	for k, v := range p {
+		opts[k] = flagValue(v)
+	}
+	return opts, nil
+}
+
+// ProfileNames returns the sorted names of every profile in the configuration
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     ([]string): The profile names
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func flagValue(v interface{}) string {
+	if l, ok := v.([]interface{}); ok {
+		s := make([]string, 0, len(l))
+		for _, i := range l {
+			s = append(s, fmt.Sprint(i))
+		}
+		return strings.Join(s, ",")
+	}
+	return fmt.Sprint(v)
+}
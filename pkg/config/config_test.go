@@ -0,0 +1,164 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testConfig = `
+profile: office
+defaults:
+  provider: cloudflare
+  padding: block
+  show-dnssec: true
+profiles:
+  office:
+    provider: internal
+    bootstrap: [192.0.2.53, 192.0.2.54]
+  private:
+    provider: quad9
+    wire: true
+providers:
+  internal:
+    description: The office resolver
+    location: Office
+    url: https://doh.example.com/dns-query
+    bootstrap: [192.0.2.53]
+    format: wire
+  legacy:
+    url: https://legacy.example.com/resolve
+probes:
+  - name: example.com
+    type: AAAA
+    provider: internal
+    expect: 2001:db8::1
+`
+
+func TestLoad(t *testing.T) {
+	c, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := map[string]Provider{
+		"internal": {
+			Description: "The office resolver",
+			Location:    "Office",
+			URL:         "https://doh.example.com/dns-query",
+			Bootstrap:   []string{"192.0.2.53"},
+			Format:      "wire",
+		},
+		"legacy": {URL: "https://legacy.example.com/resolve"},
+	}
+	if !reflect.DeepEqual(c.Providers, want) {
+		t.Errorf("providers = %+v, want %+v", c.Providers, want)
+	}
+	if want := []Probe{{Name: "example.com", Type: "AAAA", Provider: "internal", Expect: "2001:db8::1"}}; !reflect.DeepEqual(c.Probes, want) {
+		t.Errorf("probes = %+v, want %+v", c.Probes, want)
+	}
+	if got := c.ProfileNames(); !reflect.DeepEqual(got, []string{"office", "private"}) {
+		t.Errorf("ProfileNames() = %v, want [office private]", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name, contents, want string
+	}{
+		{"unknown key", "defaults: {}\nprofiel: office\n", "field profiel not found"},
+		{"unknown provider key", "providers:\n  internal:\n    url: https://doh.example.com/\n    adress: 192.0.2.53\n", "field adress not found"},
+		{"provider without url", "providers:\n  internal:\n    format: json\n", "provider internal in"},
+		{"unsupported format", "providers:\n  internal:\n    url: https://doh.example.com/\n    format: xml\n", "unsupported format xml"},
+		{"probe without name", "probes:\n  - type: A\n", "probe 0 in"},
+		{"invalid yaml", "defaults: [\n", "error parsing the config file"},
+	} {
+		_, err := Load(writeConfig(t, tt.contents))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Load() error = %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load() of a missing file error = nil")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	c, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		profile string
+		want    map[string]string
+	}{
+		{
+			// The profile named in the file applies when none is given
+			profile: "",
+			want: map[string]string{
+				"provider":    "internal",
+				"padding":     "block",
+				"show-dnssec": "true",
+				"bootstrap":   "192.0.2.53,192.0.2.54",
+			},
+		},
+		{
+			profile: "private",
+			want: map[string]string{
+				"provider":    "quad9",
+				"padding":     "block",
+				"show-dnssec": "true",
+				"wire":        "true",
+			},
+		},
+	} {
+		got, err := c.Options(tt.profile)
+		if err != nil {
+			t.Fatalf("Options(%q) error = %v", tt.profile, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Options(%q) = %v, want %v", tt.profile, got, tt.want)
+		}
+	}
+
+	_, err = c.Options("missing")
+	if err == nil || !strings.Contains(err.Error(), "available profiles: office, private") {
+		t.Errorf("Options() of an undefined profile error = %v", err)
+	}
+
+	// Without a profile only the defaults apply
+	c.Profile = ""
+	got, err := c.Options("")
+	if err != nil {
+		t.Fatalf("Options() error = %v", err)
+	}
+	if want := map[string]string{"provider": "cloudflare", "padding": "block", "show-dnssec": "true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Options() = %v, want %v", got, want)
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	got, err := DefaultPath()
+	if err != nil {
+		t.Fatalf("DefaultPath() error = %v", err)
+	}
+	if want := filepath.Join(dir, "dohdig", "config.yaml"); got != want {
+		t.Errorf("DefaultPath() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package custom
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+)
+
+// QueryRequest is the request needed to query a user defined JSON DoH endpoint
+type QueryRequest struct {
+	URL                     string
+	Resource                string
+	ResourceType            string
+	DisableDNSSECValidation bool
+	ShowDNSSEC              bool
+}
+
+// Do runs the query
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):                     An error if one exists, nil otherwise
+func (q QueryRequest) Do() (*common.QueryResponse, error) {
+	return q.DoContext(context.Background())
+}
+
+// DoContext runs the query, abandoning the HTTP request once ctx is done
+//
+// Arguments:
+//     ctx (context.Context): The context of the request
+//
+// Returns:
+//     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):                     An error if one exists, nil otherwise
+func (q QueryRequest) DoContext(ctx context.Context) (*common.QueryResponse, error) {
+	if q.URL == "" {
+		return nil, fmt.Errorf("a url is required for custom providers")
+	}
+
+	name, err := common.ToASCII(q.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(q.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the provided url: %s, err: %w", q.URL, err)
+	}
+
+	v := u.Query()
+	v.Set("name", name)
+	v.Set("type", q.ResourceType)
+	v.Set("cd", strconv.FormatBool(q.DisableDNSSECValidation))
+	v.Set("do", strconv.FormatBool(q.ShowDNSSEC))
+	u.RawQuery = v.Encode()
+
+	return common.Exchange(ctx, &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Header: http.Header{
+			"accept": []string{
+				"application/dns-json",
+			},
+		},
+	})
+}
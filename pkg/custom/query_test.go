@@ -0,0 +1,42 @@
+package custom
+
+import (
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+)
+
+func TestDo(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 28, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "example.com.", Type: 28, TTL: 300, Data: "2606:2800:220:1:248:1893:25c8:1946"}},
+	})
+
+	resp, err := QueryRequest{
+		URL:          "https://doh.example.net/resolve?token=abc",
+		Resource:     "example.com",
+		ResourceType: "AAAA",
+	}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	req := s.LastRequest()
+	if req.Host != "doh.example.net" || req.URL.Path != "/resolve" {
+		t.Errorf("request sent to %s%s, want doh.example.net/resolve", req.Host, req.URL.Path)
+	}
+	q := req.URL.Query()
+	if q.Get("token") != "abc" || q.Get("name") != "example.com" || q.Get("type") != "AAAA" {
+		t.Errorf("query = %v, want the url's token along with name and type", q)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].Data != "2606:2800:220:1:248:1893:25c8:1946" {
+		t.Errorf("answer = %+v, want a single AAAA record", resp.Answer)
+	}
+}
+
+func TestDoRequiresURL(t *testing.T) {
+	if _, err := (QueryRequest{Resource: "example.com", ResourceType: "A"}).Do(); err == nil {
+		t.Error("Do() error = nil, want a missing url error")
+	}
+}
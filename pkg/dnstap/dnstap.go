@@ -0,0 +1,248 @@
+// Package dnstap logs every lookup as dnstap CLIENT_QUERY and CLIENT_RESPONSE messages over Frame
+// Streams, to a file or to a collector listening on a unix socket
+package dnstap
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tap "github.com/dnstap/golang-dnstap"
+	framestream "github.com/farsightsec/golang-framestream"
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+	"github.com/j4ng5y/dohdig/pkg/wire"
+	"google.golang.org/protobuf/proto"
+)
+
+// SocketPrefix marks a destination as the path of a unix socket rather than a file
+const SocketPrefix = "unix:"
+
+// Timeout bounds connecting and writing to a socket, as well as its Frame Streams handshake
+const Timeout = 5 * time.Second
+
+// Version is the version string written in every dnstap message
+const Version = "dohdig"
+
+// Writer writes dnstap messages to a file or unix socket, safe for concurrent use
+type Writer struct {
+	identity []byte
+
+	mu     sync.Mutex
+	fs     *framestream.Writer
+	closer io.Closer
+}
+
+// Open creates the dnstap file, truncating it if it exists, or connects to the unix socket of a
+// collector and completes the bidirectional Frame Streams handshake with it
+//
+// Arguments:
+//     dest (string): The file to write, or unix:PATH for a socket
+//
+// Returns:
+//     (*Writer): A pointer to the open writer, or nil if an error occurred
+//     (error):   An error if one exists, nil otherwise
+func Open(dest string) (*Writer, error) {
+	var (
+		w             io.ReadWriteCloser
+		bidirectional bool
+	)
+	if strings.HasPrefix(dest, SocketPrefix) {
+		path := strings.TrimPrefix(strings.TrimPrefix(dest, SocketPrefix), "//")
+		c, err := net.DialTimeout("unix", path, Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to the dnstap socket, err: %w", err)
+		}
+		w, bidirectional = c, true
+	} else {
+		f, err := os.Create(dest)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the dnstap file, err: %w", err)
+		}
+		w = f
+	}
+
+	fs, err := framestream.NewWriter(w, &framestream.WriterOptions{
+		ContentTypes:  [][]byte{tap.FSContentType},
+		Bidirectional: bidirectional,
+		Timeout:       Timeout,
+	})
+	if err != nil {
+		w.Close()
+		return nil, fmt.Errorf("error starting the dnstap stream, err: %w", err)
+	}
+
+	identity, _ := os.Hostname()
+	return &Writer{identity: []byte(identity), fs: fs, closer: w}, nil
+}
+
+// Write writes the CLIENT_QUERY and, when a response was received, the CLIENT_RESPONSE message of
+// a lookup. Wire-format lookups are written with the messages exactly as they were exchanged.
+// JSON API lookups have no wire-format messages, so their query is packed from the query options
+// and their response from the decoded fields
+//
+// Arguments:
+//     e (pkg.provider.Event): The completed lookup
+//
+// Returns:
+//     (error): An error if one exists, nil otherwise
+func (w *Writer) Write(e provider.Event) error {
+	var query []byte
+	if e.Response != nil && e.Response.Request != nil {
+		query = e.Response.Request.QueryMessage()
+	}
+	if query == nil {
+		var err error
+		query, err = wire.QueryRequest{
+			Resource:                e.Query.Resource,
+			ResourceType:            e.Query.ResourceType,
+			EDNSClientSubnet:        e.Query.EDNSClientSubnet,
+			Padding:                 wirePadding(e.Query),
+			DisableDNSSECValidation: e.Query.DisableDNSSECValidation,
+			ShowDNSSEC:              e.Query.ShowDNSSEC,
+		}.Pack()
+		if err != nil {
+			return fmt.Errorf("error packing the dnstap query message, err: %w", err)
+		}
+	}
+
+	msg := &tap.Message{
+		Type:           tap.Message_CLIENT_QUERY.Enum(),
+		SocketProtocol: tap.SocketProtocol_DOH.Enum(),
+		QueryTimeSec:   proto.Uint64(uint64(e.Time.Unix())),
+		QueryTimeNsec:  proto.Uint32(uint32(e.Time.Nanosecond())),
+		QueryMessage:   query,
+	}
+	if e.Response != nil && e.Response.TLS != nil {
+		setResponder(msg, e.Response.TLS.RemoteAddr)
+	}
+	frames := []*tap.Message{msg}
+
+	if e.Response != nil {
+		resp, err := responseMessage(e.Response)
+		if err != nil {
+			return err
+		}
+		t := e.Time.Add(e.Latency)
+
+		r := proto.Clone(msg).(*tap.Message)
+		r.Type = tap.Message_CLIENT_RESPONSE.Enum()
+		r.ResponseTimeSec = proto.Uint64(uint64(t.Unix()))
+		r.ResponseTimeNsec = proto.Uint32(uint32(t.Nanosecond()))
+		r.ResponseMessage = resp
+		frames = append(frames, r)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fs == nil {
+		return fmt.Errorf("the dnstap writer is closed")
+	}
+	for _, m := range frames {
+		b, err := proto.Marshal(&tap.Dnstap{
+			Identity: w.identity,
+			Version:  []byte(Version),
+			Extra:    []byte(fmt.Sprintf("provider=%s endpoint=%s", e.Provider.Name, e.Endpoint())),
+			Type:     tap.Dnstap_MESSAGE.Enum(),
+			Message:  m,
+		})
+		if err != nil {
+			return fmt.Errorf("error encoding the dnstap message, err: %w", err)
+		}
+		if _, err := w.fs.WriteFrame(b); err != nil {
+			return fmt.Errorf("error writing the dnstap message, err: %w", err)
+		}
+	}
+
+	// Flush every lookup, as the command may exit without closing the writer
+	if err := w.fs.Flush(); err != nil {
+		return fmt.Errorf("error writing the dnstap message, err: %w", err)
+	}
+	return nil
+}
+
+// responseMessage returns the response message as it was received, or packs it from the decoded
+// fields for JSON API responses
+func responseMessage(resp *common.QueryResponse) ([]byte, error) {
+	if resp.Response != nil && strings.HasPrefix(resp.Response.Header.Get("Content-Type"), common.MediaTypeDNSMessage) {
+		return resp.Response.Body, nil
+	}
+	b, err := resp.MarshalWire()
+	if err != nil {
+		return nil, fmt.Errorf("error packing the dnstap response message, err: %w", err)
+	}
+	return b, nil
+}
+
+// wirePadding returns the padding policy of the query message, which only wire-format queries pad
+func wirePadding(q provider.Query) string {
+	if !q.Wire {
+		return common.PaddingNone
+	}
+	return q.Padding
+}
+
+// setResponder fills in the address and port of the DoH server a message was exchanged with
+func setResponder(msg *tap.Message, remoteAddr string) {
+	host, port, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		msg.SocketFamily = tap.SocketFamily_INET.Enum()
+		msg.ResponseAddress = ip4
+	} else {
+		msg.SocketFamily = tap.SocketFamily_INET6.Enum()
+		msg.ResponseAddress = ip.To16()
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err == nil {
+		msg.ResponsePort = proto.Uint32(uint32(p))
+	}
+}
+
+// Observe writes the messages of a lookup, reporting a failed write on stderr rather than failing
+// the lookup, so that it can be passed to pkg.provider.Observe
+//
+// Arguments:
+//     e (pkg.provider.Event): The completed lookup
+//
+// Returns:
+//     None
+func (w *Writer) Observe(e provider.Event) {
+	if err := w.Write(e); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Close ends the Frame Streams session, waiting for the collector to acknowledge it on a socket,
+// and closes the file or socket
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (error): An error if one exists, nil otherwise
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fs == nil {
+		return nil
+	}
+
+	err := w.fs.Close()
+	if cerr := w.closer.Close(); err == nil {
+		err = cerr
+	}
+	w.fs = nil
+	return err
+}
@@ -0,0 +1,182 @@
+package dnstap
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tap "github.com/dnstap/golang-dnstap"
+	framestream "github.com/farsightsec/golang-framestream"
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+	"golang.org/x/net/dns/dnsmessage"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWriteFile(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "93.184.216.34"}},
+	})
+
+	path := filepath.Join(t.TempDir(), "dohdig.dnstap")
+	w, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer provider.Observe(w.Observe)()
+
+	// One JSON API lookup and one wire-format lookup, which must look the same on the wire. The
+	// wire-format query is randomly padded, so only the message that was sent matches the server's
+	p, err := provider.Get("cloudflare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.New(provider.Query{Resource: "example.com", ResourceType: "A"}).Do(); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	wireResp, err := p.New(provider.Query{Resource: "example.com", ResourceType: "A", Wire: true, Padding: common.PaddingRandom}).Do()
+	if err != nil {
+		t.Fatalf("Do(wire) error = %v", err)
+	}
+	received := common.RequestInfo{URL: s.LastRequest().URL.String()}.QueryMessage()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := framestream.NewReader(f, &framestream.ReaderOptions{ContentTypes: [][]byte{tap.FSContentType}})
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	messages := readMessages(t, r)
+
+	want := []tap.Message_Type{
+		tap.Message_CLIENT_QUERY, tap.Message_CLIENT_RESPONSE,
+		tap.Message_CLIENT_QUERY, tap.Message_CLIENT_RESPONSE,
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("%d messages written, want %d", len(messages), len(want))
+	}
+	for i, m := range messages {
+		if m.GetType() != want[i] || m.GetSocketProtocol() != tap.SocketProtocol_DOH {
+			t.Errorf("message %d = %s over %s, want %s over DOH", i, m.GetType(), m.GetSocketProtocol(), want[i])
+		}
+		if m.GetQueryTimeSec() == 0 {
+			t.Errorf("message %d has no query time", i)
+		}
+
+		var q dnsmessage.Message
+		if err := q.Unpack(m.GetQueryMessage()); err != nil {
+			t.Fatalf("message %d query does not unpack, err: %v", i, err)
+		}
+		if len(q.Questions) != 1 || q.Questions[0].Name.String() != "example.com." || q.Questions[0].Type != dnsmessage.TypeA {
+			t.Errorf("message %d questions = %+v, want example.com. A", i, q.Questions)
+		}
+		if i >= 2 && !bytes.Equal(m.GetQueryMessage(), received) {
+			t.Errorf("message %d query = %x, want the query the server received %x", i, m.GetQueryMessage(), received)
+		}
+		if m.GetType() != tap.Message_CLIENT_RESPONSE {
+			continue
+		}
+		if i >= 2 && !bytes.Equal(m.GetResponseMessage(), wireResp.Response.Body) {
+			t.Errorf("message %d response = %x, want the response that was received %x", i, m.GetResponseMessage(), wireResp.Response.Body)
+		}
+
+		var resp dnsmessage.Message
+		if err := resp.Unpack(m.GetResponseMessage()); err != nil {
+			t.Fatalf("message %d response does not unpack, err: %v", i, err)
+		}
+		if !resp.Header.Response || len(resp.Answers) != 1 {
+			t.Fatalf("message %d response = %+v, want a response with a single answer", i, resp)
+		}
+		if a, ok := resp.Answers[0].Body.(*dnsmessage.AResource); !ok || net.IP(a.A[:]).String() != "93.184.216.34" {
+			t.Errorf("message %d answer = %+v, want 93.184.216.34", i, resp.Answers[0].Body)
+		}
+		if net.IP(m.GetResponseAddress()).String() != "127.0.0.1" || m.GetResponsePort() == 0 {
+			t.Errorf("message %d responder = %v:%d, want the test server", i, net.IP(m.GetResponseAddress()), m.GetResponsePort())
+		}
+	}
+}
+
+func TestWriteSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnstap.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets are not available: %v", err)
+	}
+	defer l.Close()
+
+	received := make(chan []*tap.Message, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer c.Close()
+		r, err := framestream.NewReader(c, &framestream.ReaderOptions{
+			ContentTypes:  [][]byte{tap.FSContentType},
+			Bidirectional: true,
+		})
+		if err != nil {
+			received <- nil
+			return
+		}
+		received <- readMessages(t, r)
+	}()
+
+	w, err := Open(SocketPrefix + path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := w.Write(provider.Event{
+		Provider: provider.Provider{Name: "google", Endpoint: "https://dns.google.com/resolve"},
+		Query:    provider.Query{Resource: "example.com", ResourceType: "AAAA"},
+	}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A failed lookup has no response, so only its query is written
+	messages := <-received
+	if len(messages) != 1 || messages[0].GetType() != tap.Message_CLIENT_QUERY {
+		t.Errorf("messages = %v, want a single CLIENT_QUERY", messages)
+	}
+}
+
+func readMessages(t *testing.T, r *framestream.Reader) []*tap.Message {
+	var messages []*tap.Message
+	buf := make([]byte, framestream.MAX_CONTROL_FRAME_SIZE*16)
+	for {
+		n, err := r.ReadFrame(buf)
+		if err == io.EOF || err == framestream.EOF {
+			return messages
+		}
+		if err != nil {
+			t.Errorf("ReadFrame() error = %v", err)
+			return messages
+		}
+
+		var d tap.Dnstap
+		if err := proto.Unmarshal(buf[:n], &d); err != nil {
+			t.Errorf("frame is not a dnstap message, err: %v", err)
+			return messages
+		}
+		if string(d.GetVersion()) != Version || d.GetType() != tap.Dnstap_MESSAGE {
+			t.Errorf("dnstap = %s %s, want a %s MESSAGE", d.GetVersion(), d.GetType(), Version)
+		}
+		messages = append(messages, d.GetMessage())
+	}
+}
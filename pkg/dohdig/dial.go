@@ -0,0 +1,237 @@
+package dohdig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/provider"
+	"github.com/j4ng5y/dohdig/pkg/wire"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Dial returns a dial function for net.Resolver that forwards the wire-format queries the pure Go
+// resolver writes to the named provider, so that
+//     &net.Resolver{PreferGo: true, Dial: dohdig.Dial("cloudflare")}
+// resolves every name over DoH. The address of the name server the resolver dials is ignored.
+// Providers that need a profile ID, such as nextdns, are dialed through Resolver.Dial instead
+//
+// Arguments:
+//     providerName (string): The name of the registered provider, DefaultProvider when empty
+//
+// Returns:
+//     (func(context.Context, string, string) (net.Conn, error)): The dial function
+func Dial(providerName string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return (&Resolver{Provider: providerName}).Dial
+}
+
+// Dial is a dial function for net.Resolver that forwards the wire-format queries the pure Go
+// resolver writes to the resolver's provider, with its NextDNS profile ID
+//
+// Arguments:
+//     ctx     (context.Context): The context of the dial, unused as nothing is dialed until a
+//                                query is written
+//     network (string):          The network, one of udp, udp4, udp6, tcp, tcp4 or tcp6
+//     address (string):          The address of the name server, ignored
+//
+// Returns:
+//     (net.Conn): The connection, a net.PacketConn for datagram networks
+//     (error):    A *net.OpError if one exists, nil otherwise
+func (r *Resolver) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	endpoint, err := r.wireURL()
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	c := &conn{addr: dohAddr{network: network, endpoint: endpoint}}
+	switch network {
+	case "udp", "udp4", "udp6":
+		// The Go resolver tells datagram and stream connections apart by net.PacketConn
+		return &packetConn{c}, nil
+	case "tcp", "tcp4", "tcp6":
+		c.stream = true
+		return c, nil
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+}
+
+// wireURL returns the wire-format endpoint of the resolver's provider, which for NextDNS carries
+// the profile ID in its path
+func (r *Resolver) wireURL() (string, error) {
+	p, err := provider.Get(r.providerName())
+	if err != nil {
+		return "", err
+	}
+	if p.Name != "nextdns" {
+		return p.WireURL(), nil
+	}
+	if r.NextDNSID == "" {
+		return "", fmt.Errorf("a NextDNS profile ID is required")
+	}
+	return strings.TrimSuffix(p.WireURL(), "/") + "/" + url.PathEscape(r.NextDNSID), nil
+}
+
+// dohAddr is the address of the DoH endpoint a conn forwards queries to
+type dohAddr struct {
+	network  string
+	endpoint string
+}
+
+func (a dohAddr) Network() string { return a.network }
+func (a dohAddr) String() string  { return a.endpoint }
+
+// conn is a net.Conn that answers the DNS messages written to it with the responses of a DoH
+// endpoint. Streams carry each message behind a two octet length, as DNS over TCP does
+type conn struct {
+	addr   dohAddr
+	stream bool
+
+	mu       sync.Mutex
+	deadline time.Time
+	closed   bool
+	pending  []byte
+	buffered bytes.Buffer
+	messages [][]byte
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, c.opError("write", io.ErrClosedPipe)
+	}
+
+	if !c.stream {
+		resp, err := c.forward(b)
+		if err != nil {
+			return 0, c.opError("write", err)
+		}
+		c.messages = append(c.messages, resp)
+		return len(b), nil
+	}
+
+	c.pending = append(c.pending, b...)
+	for len(c.pending) >= 2 {
+		n := int(c.pending[0])<<8 | int(c.pending[1])
+		if len(c.pending) < 2+n {
+			break
+		}
+		resp, err := c.forward(c.pending[2 : 2+n])
+		if err != nil {
+			return 0, c.opError("write", err)
+		}
+		c.pending = c.pending[2+n:]
+		c.buffered.Write([]byte{byte(len(resp) >> 8), byte(len(resp))})
+		c.buffered.Write(resp)
+	}
+	return len(b), nil
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, c.opError("read", io.ErrClosedPipe)
+	}
+
+	if c.stream {
+		return c.buffered.Read(b)
+	}
+
+	if len(c.messages) == 0 {
+		return 0, io.EOF
+	}
+	msg := c.messages[0]
+	c.messages = c.messages[1:]
+	if len(msg) > len(b) {
+		// A datagram that does not fit is truncated, which sends the Go resolver to TCP
+		msg = truncate(msg, len(b))
+	}
+	return copy(b, msg), nil
+}
+
+func (c *conn) forward(msg []byte) ([]byte, error) {
+	ctx := context.Background()
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	return wire.Forward(ctx, c.addr.endpoint, msg)
+}
+
+func (c *conn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: c.addr.network, Addr: c.addr, Err: err}
+}
+
+func (c *conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *conn) LocalAddr() net.Addr  { return c.addr }
+func (c *conn) RemoteAddr() net.Addr { return c.addr }
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+// SetReadDeadline is a no-op as responses are already buffered by the time they are read
+func (c *conn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+// packetConn is the datagram form of conn, where every Read returns a single message
+type packetConn struct {
+	*conn
+}
+
+func (c *packetConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.addr, err
+}
+
+func (c *packetConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.Write(b)
+}
+
+// truncate reduces a response to its header and question with the TC bit set, as a server does
+// when the answer does not fit in a datagram
+func truncate(msg []byte, size int) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return msg[:size]
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return msg[:size]
+	}
+
+	h.Truncated = true
+	b := dnsmessage.NewBuilder(make([]byte, 0, size), h)
+	if err := b.StartQuestions(); err != nil {
+		return msg[:size]
+	}
+	for _, q := range questions {
+		if err := b.Question(q); err != nil {
+			return msg[:size]
+		}
+	}
+	out, err := b.Finish()
+	if err != nil || len(out) > size {
+		return msg[:size]
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package dohdig
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"github.com/j4ng5y/dohdig/pkg/wire"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDial(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{Records: records("example.com.", 1, "192.0.2.1")})
+	s.Handle("example.com", 28, dohtest.Answer{Records: records("example.com.", 28, "2001:db8::1")})
+
+	// Too large for a datagram, so the Go resolver retries it over TCP
+	var long []string
+	for i := 0; i < 10; i++ {
+		long = append(long, `"`+strings.Repeat(string(rune('a'+i)), 200)+`"`)
+	}
+	s.Handle("big.example.com", 16, dohtest.Answer{Records: records("big.example.com.", 16, long...)})
+
+	r := &net.Resolver{PreferGo: true, Dial: Dial("cloudflare")}
+	ctx := context.Background()
+
+	addrs, err := r.LookupHost(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("LookupHost() error = %v", err)
+	}
+	if want := []string{"192.0.2.1", "2001:db8::1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("LookupHost() = %v, want %v", addrs, want)
+	}
+
+	before := len(s.Requests())
+	txt, err := r.LookupTXT(ctx, "big.example.com.")
+	if err != nil {
+		t.Fatalf("LookupTXT() error = %v", err)
+	}
+	if len(txt) != len(long) {
+		t.Errorf("LookupTXT() returned %d records, want %d", len(txt), len(long))
+	}
+	if n := len(s.Requests()) - before; n != 2 {
+		t.Errorf("LookupTXT() sent %d requests, want a truncated datagram and a TCP retry", n)
+	}
+	for _, req := range s.Requests() {
+		if req.Host != "cloudflare-dns.com" || req.Message == nil || req.Message.Header.ID != 0 {
+			t.Errorf("request sent to %s with message %v, want a wire-format query with ID 0 to cloudflare-dns.com", req.Host, req.Message)
+		}
+	}
+}
+
+func TestDialStream(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{Records: records("example.com.", 1, "192.0.2.1")})
+
+	c, err := Dial("cloudflare")(context.Background(), "tcp", "192.0.2.53:53")
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer c.Close()
+	if _, ok := c.(net.PacketConn); ok {
+		t.Error("Dial() over tcp returned a net.PacketConn")
+	}
+
+	msg, err := wire.QueryRequest{Resource: "example.com", ResourceType: "A"}.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg[0], msg[1] = 0x12, 0x34
+
+	// Write the length and the message separately, as a stream may deliver them
+	if _, err := c.Write([]byte{byte(len(msg) >> 8), byte(len(msg))}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if _, err := c.Write(msg); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(c, length[:]); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(c, resp); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	var m dnsmessage.Message
+	if err := m.Unpack(resp); err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	if m.Header.ID != 0x1234 || len(m.Answers) != 1 {
+		t.Errorf("response ID = %#x with %d answers, want 0x1234 with 1", m.Header.ID, len(m.Answers))
+	}
+	if a, ok := m.Answers[0].Body.(*dnsmessage.AResource); !ok || net.IP(a.A[:]).String() != "192.0.2.1" {
+		t.Errorf("answer = %v, want 192.0.2.1", m.Answers[0].Body)
+	}
+}
+
+func TestDialDatagram(t *testing.T) {
+	c, err := Dial("cloudflare")(context.Background(), "udp", "192.0.2.53:53")
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer c.Close()
+	if _, ok := c.(net.PacketConn); !ok {
+		t.Error("Dial() over udp did not return a net.PacketConn")
+	}
+
+	if _, err := Dial("cloudflare")(context.Background(), "unix", "/tmp/dns.sock"); err == nil {
+		t.Error("Dial() over unix error = nil")
+	}
+}
+
+func TestDialNextDNS(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{Records: records("example.com.", 1, "192.0.2.1")})
+	s.Handle("example.com", 28, dohtest.Answer{Records: records("example.com.", 28, "2001:db8::1")})
+
+	if _, err := Dial("nextdns")(context.Background(), "udp", "192.0.2.53:53"); err == nil {
+		t.Error("Dial() of nextdns without a profile ID error = nil")
+	}
+
+	r := &net.Resolver{PreferGo: true, Dial: (&Resolver{Provider: "nextdns", NextDNSID: "abc123"}).Dial}
+	if _, err := r.LookupHost(context.Background(), "example.com."); err != nil {
+		t.Fatalf("LookupHost() error = %v", err)
+	}
+	if req := s.LastRequest(); req.Host != "dns.nextdns.io" || req.URL.Path != "/abc123" {
+		t.Errorf("request sent to %s%s, want dns.nextdns.io/abc123", req.Host, req.URL.Path)
+	}
+}
@@ -0,0 +1,413 @@
+// Package dohdig resolves names through the registered DoH providers with the same API and
+// error semantics as net.Resolver
+package dohdig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+// DefaultProvider is the provider a Resolver uses when none is set
+const DefaultProvider = "google"
+
+// Resolver looks up names through a registered DoH provider
+type Resolver struct {
+	// Provider is the name of the registered provider to use, DefaultProvider when empty
+	Provider string
+
+	// Wire sends RFC 8484 wire-format queries instead of using the provider's JSON API
+	Wire bool
+
+	// NextDNSID is the NextDNS profile ID, which the nextdns provider requires
+	NextDNSID string
+}
+
+// lookup runs a single query, mapping transport failures and error response codes to
+// *net.DNSError the same way the Go resolver does
+func (r *Resolver) lookup(ctx context.Context, name, typeName string) (*common.QueryResponse, error) {
+	p, err := provider.Get(r.providerName())
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name}
+	}
+	if p.Name == "nextdns" && r.NextDNSID == "" {
+		return nil, &net.DNSError{Err: "a NextDNS profile ID is required", Name: name, Server: p.Host()}
+	}
+
+	resp, err := p.New(provider.Query{
+		Resource:     name,
+		ResourceType: typeName,
+		Padding:      common.PaddingBlock,
+		NextDNSID:    r.NextDNSID,
+		Wire:         r.Wire,
+	}).DoContext(ctx)
+	if ctx.Err() != nil {
+		return nil, &net.DNSError{
+			Err:       ctx.Err().Error(),
+			Name:      name,
+			Server:    p.Host(),
+			IsTimeout: ctx.Err() == context.DeadlineExceeded,
+		}
+	}
+	if err != nil {
+		var ne net.Error
+		ok := errors.As(err, &ne)
+		return nil, &net.DNSError{
+			Err:         err.Error(),
+			Name:        name,
+			Server:      p.Host(),
+			IsTimeout:   ok && ne.Timeout(),
+			IsTemporary: true,
+		}
+	}
+
+	switch resp.StatusCode {
+	case 0:
+		return resp, nil
+	case 3:
+		return nil, notFound(name, p.Host())
+	case 2:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, Server: p.Host(), IsTemporary: true}
+	default:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, Server: p.Host()}
+	}
+}
+
+func (r *Resolver) providerName() string {
+	if r.Provider == "" {
+		return DefaultProvider
+	}
+	return r.Provider
+}
+
+// server returns the host of the provider's endpoint, which errors report as the server
+func (r *Resolver) server() string {
+	p, err := provider.Get(r.providerName())
+	if err != nil {
+		return ""
+	}
+	return p.Host()
+}
+
+func notFound(name, server string) *net.DNSError {
+	return &net.DNSError{Err: "no such host", Name: name, Server: server, IsNotFound: true}
+}
+
+// answers returns the typed data of the answers of type t, failing with a not found error when
+// there are none
+func (r *Resolver) answers(ctx context.Context, name, typeName string, t int) ([]common.RData, error) {
+	resp, err := r.lookup(ctx, name, typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []common.RData
+	for _, a := range resp.Answer {
+		if a.Type == t && a.RData != nil {
+			out = append(out, a.RData)
+		}
+	}
+	if len(out) == 0 {
+		return nil, notFound(name, r.server())
+	}
+	return out, nil
+}
+
+// LookupHost looks up the given host, returning a slice of its addresses
+//
+// Arguments:
+//     ctx  (context.Context): The context of the lookup
+//     host (string):          The host name
+//
+// Returns:
+//     ([]string): The IPv4 and IPv6 addresses of the host
+//     (error):    A *net.DNSError if one exists, nil otherwise
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		out = append(out, a.String())
+	}
+	return out, nil
+}
+
+// LookupIPAddr looks up the given host, querying its A and AAAA records in parallel
+//
+// Arguments:
+//     ctx  (context.Context): The context of the lookup
+//     host (string):          The host name
+//
+// Returns:
+//     ([]net.IPAddr): The IPv4 and IPv6 addresses of the host
+//     (error):        A *net.DNSError if one exists, nil otherwise
+func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IPAddr{{IP: ip}}, nil
+	}
+
+	var (
+		wg      sync.WaitGroup
+		results [2][]common.RData
+		errs    [2]error
+	)
+	for i, t := range []struct {
+		name string
+		code int
+	}{{"A", 1}, {"AAAA", 28}} {
+		wg.Add(1)
+		go func(i int, name string, code int) {
+			defer wg.Done()
+			results[i], errs[i] = r.answers(ctx, host, name, code)
+		}(i, t.name, t.code)
+	}
+	wg.Wait()
+
+	var addrs []net.IPAddr
+	for _, result := range results {
+		for _, d := range result {
+			switch a := d.(type) {
+			case common.AData:
+				addrs = append(addrs, net.IPAddr{IP: a.Address})
+			case common.AAAAData:
+				addrs = append(addrs, net.IPAddr{IP: a.Address})
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		// Prefer reporting a failure over the not found error of a family without addresses
+		for _, err := range errs {
+			if de, ok := err.(*net.DNSError); ok && !de.IsNotFound {
+				return nil, err
+			}
+		}
+		return nil, notFound(host, r.server())
+	}
+	return addrs, nil
+}
+
+// LookupCNAME returns the canonical name of the given host, following its CNAME records
+//
+// Arguments:
+//     ctx  (context.Context): The context of the lookup
+//     host (string):          The host name
+//
+// Returns:
+//     (string): The canonical name, fully qualified
+//     (error):  A *net.DNSError if one exists, nil otherwise
+func (r *Resolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	resp, err := r.lookup(ctx, host, "A")
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Answer) == 0 {
+		return "", notFound(host, r.server())
+	}
+
+	targets := make(map[string]string)
+	for _, a := range resp.Answer {
+		if c, ok := a.RData.(common.CNAMEData); ok {
+			targets[common.FQDN(a.Name)] = common.FQDN(c.Target)
+		}
+	}
+
+	cname := common.FQDN(host)
+	for i := 0; i < len(targets); i++ {
+		next, ok := targets[cname]
+		if !ok {
+			break
+		}
+		cname = next
+	}
+	return cname, nil
+}
+
+// LookupMX returns the MX records of the given domain, sorted by preference
+//
+// Arguments:
+//     ctx  (context.Context): The context of the lookup
+//     name (string):          The domain name
+//
+// Returns:
+//     ([]*net.MX): The MX records
+//     (error):     A *net.DNSError if one exists, nil otherwise
+func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	data, err := r.answers(ctx, name, "MX", 15)
+	if err != nil {
+		return nil, err
+	}
+
+	mx := make([]*net.MX, 0, len(data))
+	for _, d := range data {
+		if m, ok := d.(common.MXData); ok {
+			mx = append(mx, &net.MX{Host: common.FQDN(m.Host), Pref: uint16(m.Preference)})
+		}
+	}
+	sort.SliceStable(mx, func(i, j int) bool { return mx[i].Pref < mx[j].Pref })
+	return mx, nil
+}
+
+// LookupNS returns the NS records of the given domain
+//
+// Arguments:
+//     ctx  (context.Context): The context of the lookup
+//     name (string):          The domain name
+//
+// Returns:
+//     ([]*net.NS): The NS records
+//     (error):     A *net.DNSError if one exists, nil otherwise
+func (r *Resolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	data, err := r.answers(ctx, name, "NS", 2)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := make([]*net.NS, 0, len(data))
+	for _, d := range data {
+		if n, ok := d.(common.NSData); ok {
+			ns = append(ns, &net.NS{Host: common.FQDN(n.Host)})
+		}
+	}
+	return ns, nil
+}
+
+// LookupSRV looks up the _service._proto.name SRV records, or name itself when both service
+// and proto are empty, sorted by priority and then by weight
+//
+// Arguments:
+//     ctx     (context.Context): The context of the lookup
+//     service (string):          The service name
+//     proto   (string):          The protocol, such as tcp or udp
+//     name    (string):          The domain name
+//
+// Returns:
+//     (string):      The name that was looked up
+//     ([]*net.SRV): The SRV records
+//     (error):       A *net.DNSError if one exists, nil otherwise
+func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+
+	data, err := r.answers(ctx, target, "SRV", 33)
+	if err != nil {
+		return "", nil, err
+	}
+
+	srv := make([]*net.SRV, 0, len(data))
+	for _, d := range data {
+		if s, ok := d.(common.SRVData); ok {
+			srv = append(srv, &net.SRV{
+				Target:   common.FQDN(s.Target),
+				Port:     uint16(s.Port),
+				Priority: uint16(s.Priority),
+				Weight:   uint16(s.Weight),
+			})
+		}
+	}
+	sort.SliceStable(srv, func(i, j int) bool {
+		if srv[i].Priority != srv[j].Priority {
+			return srv[i].Priority < srv[j].Priority
+		}
+		return srv[i].Weight > srv[j].Weight
+	})
+	return common.FQDN(target), srv, nil
+}
+
+// LookupTXT returns the TXT records of the given domain, with the character strings of each
+// record concatenated
+//
+// Arguments:
+//     ctx  (context.Context): The context of the lookup
+//     name (string):          The domain name
+//
+// Returns:
+//     ([]string): The TXT records
+//     (error):    A *net.DNSError if one exists, nil otherwise
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	data, err := r.answers(ctx, name, "TXT", 16)
+	if err != nil {
+		return nil, err
+	}
+
+	txt := make([]string, 0, len(data))
+	for _, d := range data {
+		if t, ok := d.(common.TXTData); ok {
+			txt = append(txt, t.Joined())
+		}
+	}
+	return txt, nil
+}
+
+// LookupAddr performs a reverse lookup for the given address
+//
+// Arguments:
+//     ctx  (context.Context): The context of the lookup
+//     addr (string):          The IPv4 or IPv6 address
+//
+// Returns:
+//     ([]string): The names mapping to the address
+//     (error):    A *net.DNSError if one exists, nil otherwise
+func (r *Resolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := ReverseName(addr)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: addr}
+	}
+
+	data, err := r.answers(ctx, name, "PTR", 12)
+	if err != nil {
+		if de, ok := err.(*net.DNSError); ok {
+			de.Name = addr
+		}
+		return nil, err
+	}
+
+	names := make([]string, 0, len(data))
+	for _, d := range data {
+		if p, ok := d.(common.PTRData); ok {
+			names = append(names, common.FQDN(p.Host))
+		}
+	}
+	return names, nil
+}
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name of an IP address
+//
+// Arguments:
+//     addr (string): The IPv4 or IPv6 address
+//
+// Returns:
+//     (string): The reverse lookup name
+//     (error):  An error if addr is not an IP address, nil otherwise
+func ReverseName(addr string) (string, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", &net.AddrError{Err: "unrecognized address", Addr: addr}
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0]), nil
+	}
+
+	const hexDigits = "0123456789abcdef"
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[ip[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[ip[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String(), nil
+}
@@ -0,0 +1,207 @@
+package dohdig
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+)
+
+func records(name string, t int, data ...string) []dohtest.Record {
+	var out []dohtest.Record
+	for _, d := range data {
+		out = append(out, dohtest.Record{Name: name, Type: t, TTL: 300, Data: d})
+	}
+	return out
+}
+
+func newServer() *dohtest.Server {
+	s := dohtest.NewServer()
+	s.Handle("example.com", 1, dohtest.Answer{Records: records("example.com.", 1, "192.0.2.1")})
+	s.Handle("example.com", 28, dohtest.Answer{Records: records("example.com.", 28, "2001:db8::1")})
+	s.Handle("v4.example.com", 1, dohtest.Answer{Records: records("v4.example.com.", 1, "192.0.2.4")})
+	s.Handle("www.example.com", 1, dohtest.Answer{Records: append(
+		records("www.example.com.", 5, "web.example.com."),
+		append(records("web.example.com.", 5, "example.com."), records("example.com.", 1, "192.0.2.1")...)...,
+	)})
+	s.Handle("example.com", 15, dohtest.Answer{Records: records("example.com.", 15, "20 mx2.example.com.", "10 mx1.example.com.")})
+	s.Handle("example.com", 2, dohtest.Answer{Records: records("example.com.", 2, "ns1.example.net.", "ns2.example.net.")})
+	s.Handle("_sip._tcp.example.com", 33, dohtest.Answer{Records: records("_sip._tcp.example.com.", 33,
+		"20 5 5060 sip3.example.com.", "10 1 5060 sip2.example.com.", "10 9 5060 sip1.example.com.")})
+	s.Handle("example.com", 16, dohtest.Answer{Records: records("example.com.", 16, `"v=spf1 " "-all"`, `"hello"`)})
+	s.Handle("1.2.0.192.in-addr.arpa", 12, dohtest.Answer{Records: records("1.2.0.192.in-addr.arpa.", 12, "example.com.")})
+	s.Handle("empty.example.com", 1, dohtest.Answer{})
+	s.Handle("fail.example.com", 1, dohtest.Answer{Status: 2})
+	return s
+}
+
+func TestLookup(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, wire := range []bool{false, true} {
+		r := &Resolver{Provider: "cloudflare", Wire: wire}
+
+		hosts, err := r.LookupHost(ctx, "example.com")
+		if err != nil || !reflect.DeepEqual(hosts, []string{"192.0.2.1", "2001:db8::1"}) {
+			t.Errorf("wire=%v: LookupHost() = %v, %v", wire, hosts, err)
+		}
+		if hosts, err := r.LookupHost(ctx, "v4.example.com"); err != nil || !reflect.DeepEqual(hosts, []string{"192.0.2.4"}) {
+			t.Errorf("wire=%v: LookupHost() of an IPv4 only host = %v, %v", wire, hosts, err)
+		}
+		if addrs, err := r.LookupIPAddr(ctx, "192.0.2.9"); err != nil || len(addrs) != 1 || addrs[0].String() != "192.0.2.9" {
+			t.Errorf("wire=%v: LookupIPAddr() of an address = %v, %v", wire, addrs, err)
+		}
+
+		if cname, err := r.LookupCNAME(ctx, "www.example.com"); err != nil || cname != "example.com." {
+			t.Errorf("wire=%v: LookupCNAME() = %q, %v, want example.com.", wire, cname, err)
+		}
+		if cname, err := r.LookupCNAME(ctx, "example.com"); err != nil || cname != "example.com." {
+			t.Errorf("wire=%v: LookupCNAME() without an alias = %q, %v, want example.com.", wire, cname, err)
+		}
+
+		mx, err := r.LookupMX(ctx, "example.com")
+		if want := []*net.MX{{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}}; err != nil || !reflect.DeepEqual(mx, want) {
+			t.Errorf("wire=%v: LookupMX() = %v, %v", wire, mx, err)
+		}
+
+		ns, err := r.LookupNS(ctx, "example.com")
+		if want := []*net.NS{{Host: "ns1.example.net."}, {Host: "ns2.example.net."}}; err != nil || !reflect.DeepEqual(ns, want) {
+			t.Errorf("wire=%v: LookupNS() = %v, %v", wire, ns, err)
+		}
+
+		cname, srv, err := r.LookupSRV(ctx, "sip", "tcp", "example.com")
+		want := []*net.SRV{
+			{Target: "sip1.example.com.", Port: 5060, Priority: 10, Weight: 9},
+			{Target: "sip2.example.com.", Port: 5060, Priority: 10, Weight: 1},
+			{Target: "sip3.example.com.", Port: 5060, Priority: 20, Weight: 5},
+		}
+		if err != nil || cname != "_sip._tcp.example.com." || !reflect.DeepEqual(srv, want) {
+			t.Errorf("wire=%v: LookupSRV() = %q, %v, %v", wire, cname, srv, err)
+		}
+
+		txt, err := r.LookupTXT(ctx, "example.com")
+		if err != nil || !reflect.DeepEqual(txt, []string{"v=spf1 -all", "hello"}) {
+			t.Errorf("wire=%v: LookupTXT() = %q, %v", wire, txt, err)
+		}
+
+		names, err := r.LookupAddr(ctx, "192.0.2.1")
+		if err != nil || !reflect.DeepEqual(names, []string{"example.com."}) {
+			t.Errorf("wire=%v: LookupAddr() = %v, %v", wire, names, err)
+		}
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+	ctx := context.Background()
+	r := &Resolver{Provider: "cloudflare"}
+
+	for _, tt := range []struct {
+		name   string
+		lookup func() error
+		want   net.DNSError
+	}{
+		{
+			name:   "nxdomain",
+			lookup: func() error { _, err := r.LookupHost(ctx, "missing.example.com"); return err },
+			want:   net.DNSError{Err: "no such host", Name: "missing.example.com", Server: "cloudflare-dns.com", IsNotFound: true},
+		},
+		{
+			name:   "empty answer",
+			lookup: func() error { _, err := r.LookupHost(ctx, "empty.example.com"); return err },
+			want:   net.DNSError{Err: "no such host", Name: "empty.example.com", Server: "cloudflare-dns.com", IsNotFound: true},
+		},
+		{
+			name:   "empty mx answer",
+			lookup: func() error { _, err := r.LookupMX(ctx, "empty.example.com"); return err },
+			want:   net.DNSError{Err: "no such host", Name: "empty.example.com", Server: "cloudflare-dns.com", IsNotFound: true},
+		},
+		{
+			name:   "empty cname answer",
+			lookup: func() error { _, err := r.LookupCNAME(ctx, "empty.example.com"); return err },
+			want:   net.DNSError{Err: "no such host", Name: "empty.example.com", Server: "cloudflare-dns.com", IsNotFound: true},
+		},
+		{
+			name:   "servfail",
+			lookup: func() error { _, err := r.LookupCNAME(ctx, "fail.example.com"); return err },
+			want:   net.DNSError{Err: "server misbehaving", Name: "fail.example.com", Server: "cloudflare-dns.com", IsTemporary: true},
+		},
+		{
+			name:   "reverse nxdomain",
+			lookup: func() error { _, err := r.LookupAddr(ctx, "192.0.2.2"); return err },
+			want:   net.DNSError{Err: "no such host", Name: "192.0.2.2", Server: "cloudflare-dns.com", IsNotFound: true},
+		},
+		{
+			name:   "nextdns without a profile",
+			lookup: func() error { _, err := (&Resolver{Provider: "nextdns"}).LookupHost(ctx, "example.com"); return err },
+			want:   net.DNSError{Err: "a NextDNS profile ID is required", Name: "example.com", Server: "dns.nextdns.io"},
+		},
+	} {
+		err := tt.lookup()
+		de, ok := err.(*net.DNSError)
+		if !ok {
+			t.Errorf("%s: error = %v, want a *net.DNSError", tt.name, err)
+			continue
+		}
+		if *de != tt.want {
+			t.Errorf("%s: error = %+v, want %+v", tt.name, *de, tt.want)
+		}
+	}
+}
+
+func TestLookupTimeout(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+	s.Handle("slow.example.com", 1, dohtest.Answer{Delay: time.Minute, Records: records("slow.example.com.", 1, "192.0.2.1")})
+	r := &Resolver{Provider: "cloudflare"}
+
+	// A cancelled context abandons the HTTP request rather than waiting for the client timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := r.LookupCNAME(ctx, "slow.example.com")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("LookupCNAME() returned after %s, want it to stop at the deadline", elapsed)
+	}
+	de, ok := err.(*net.DNSError)
+	if !ok || !de.IsTimeout || de.Server != "cloudflare-dns.com" {
+		t.Errorf("LookupCNAME() past the deadline error = %#v, want a timeout", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = r.LookupCNAME(ctx, "slow.example.com")
+	if de, ok := err.(*net.DNSError); !ok || de.IsTimeout || de.Err != context.Canceled.Error() {
+		t.Errorf("LookupCNAME() with a cancelled context error = %#v, want a cancellation", err)
+	}
+
+	// The client timeout is reported as a timeout of the transport
+	timeout := common.Client.Timeout
+	common.Client.Timeout = 50 * time.Millisecond
+	defer func() { common.Client.Timeout = timeout }()
+	_, err = r.LookupCNAME(context.Background(), "slow.example.com")
+	if de, ok := err.(*net.DNSError); !ok || !de.IsTimeout || !de.IsTemporary {
+		t.Errorf("LookupCNAME() past the client timeout error = %#v, want a temporary timeout", err)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	for addr, want := range map[string]string{
+		"192.0.2.1":   "1.2.0.192.in-addr.arpa.",
+		"2001:db8::1": "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	} {
+		if got, err := ReverseName(addr); err != nil || got != want {
+			t.Errorf("ReverseName(%s) = %q, %v, want %q", addr, got, err, want)
+		}
+	}
+	if _, err := ReverseName("example.com"); err == nil {
+		t.Error("ReverseName() of a name error = nil")
+	}
+}
@@ -0,0 +1,399 @@
+// Package dohtest provides a fake DoH server that answers both the JSON API and RFC 8484
+// wire-format queries with scripted answers, so that providers can be tested without a network
+package dohtest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Record is a scripted resource record, with its data in presentation format
+type Record struct {
+	Name string
+	Type int
+	TTL  int
+	Data string
+}
+
+// Answer is the scripted response to a name and type
+type Answer struct {
+	Status         int
+	AD             bool
+	Records        []Record
+	Authority      []Record
+	ExtendedErrors []common.ExtendedError
+
+	// Header holds extra response headers, such as the ones providers use for metadata
+	Header http.Header
+
+	// Delay holds the response back, unless the client gives up on the request first
+	Delay time.Duration
+}
+
+// wait holds the response back for the answer's delay, reporting false if the request was
+// cancelled in the meantime
+func (a Answer) wait(ctx context.Context) bool {
+	if a.Delay <= 0 {
+		return true
+	}
+	t := time.NewTimer(a.Delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Request is a request the server received
+type Request struct {
+	Method string
+	Host   string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+
+	// Message is the decoded query of a wire-format request, nil for JSON requests
+	Message *dnsmessage.Message
+}
+
+// Server is a fake DoH server that every request of the shared client is routed to while it runs
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	answers   map[string]Answer
+	requests  []Request
+	transport http.RoundTripper
+}
+
+// NewServer starts a Server and routes the shared client to it, whatever the host of the request,
+// until it is closed
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (*Server): A pointer to the running server
+func NewServer() *Server {
+	s := &Server{answers: make(map[string]Answer)}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+
+	addr := s.Listener.Addr().String()
+	s.transport = common.Client.Transport
+	common.Client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	return s
+}
+
+// Close stops the server and restores the shared client's transport
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     None
+func (s *Server) Close() {
+	common.Client.Transport = s.transport
+	s.Server.Close()
+}
+
+// Handle scripts the answer to queries for name and type t
+//
+// Arguments:
+//     name (string): The queried name
+//     t    (int):    The queried record type
+//     a    (Answer): The answer to respond with
+//
+// Returns:
+//     None
+func (s *Server) Handle(name string, t int, a Answer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.answers[key(name, t)] = a
+}
+
+// HandleHost scripts the answer to queries for name and type t that are sent to host, taking
+// precedence over the answer of Handle, so that providers can be told apart
+//
+// Arguments:
+//     host (string): The host name of the provider's endpoint
+//     name (string): The queried name
+//     t    (int):    The queried record type
+//     a    (Answer): The answer to respond with
+//
+// Returns:
+//     None
+func (s *Server) HandleHost(host, name string, t int, a Answer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.answers[strings.ToLower(host)+" "+key(name, t)] = a
+}
+
+// Requests returns every request the server has received, in order
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     ([]Request): The requests
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the most recent request the server received
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (Request): The request, or the zero Request if there has been none
+func (s *Server) LastRequest() Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return Request{}
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+func key(name string, t int) string {
+	return fmt.Sprintf("%s/%d", strings.ToLower(strings.TrimSuffix(name, ".")), t)
+}
+
+func (s *Server) answer(host, name string, t int) Answer {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.answers[strings.ToLower(host)+" "+key(name, t)]; ok {
+		return a
+	}
+	a, ok := s.answers[key(name, t)]
+	if !ok {
+		return Answer{Status: 3}
+	}
+	return a
+}
+
+func (s *Server) record(req Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := Request{Method: r.Method, Host: r.Host, URL: r.URL, Header: r.Header, Body: body}
+
+	var msg []byte
+	switch {
+	case r.Method == http.MethodPost && r.Header.Get("Content-Type") == common.MediaTypeDNSMessage:
+		msg = body
+	case r.URL.Query().Get("dns") != "":
+		msg, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		if err != nil {
+			s.record(req)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if msg == nil {
+		s.record(req)
+		s.serveJSON(w, r)
+		return
+	}
+
+	req.Message = new(dnsmessage.Message)
+	if err := req.Message.Unpack(msg); err != nil {
+		s.record(req)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.record(req)
+	s.serveWire(w, r, req.Message)
+}
+
+// jsonRecord is a record in the JSON API format
+type jsonRecord struct {
+	Name string `json:"name"`
+	Type int    `json:"type"`
+	TTL  int    `json:"TTL"`
+	Data string `json:"data"`
+}
+
+func (s *Server) serveJSON(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	t, err := common.TypeFromName(r.URL.Query().Get("type"))
+	if err != nil || name == "" {
+		http.Error(w, "invalid name or type", http.StatusBadRequest)
+		return
+	}
+
+	a := s.answer(r.Host, name, t)
+	if !a.wait(r.Context()) {
+		return
+	}
+	resp := map[string]interface{}{
+		"Status":   a.Status,
+		"TC":       false,
+		"RD":       true,
+		"RA":       true,
+		"AD":       a.AD,
+		"CD":       r.URL.Query().Get("cd") == "true" || r.URL.Query().Get("cd") == "1",
+		"Question": []map[string]interface{}{{"name": name, "type": t}},
+	}
+	if len(a.Records) > 0 {
+		resp["Answer"] = jsonRecords(a.Records)
+	}
+	if len(a.Authority) > 0 {
+		resp["Authority"] = jsonRecords(a.Authority)
+	}
+	if len(a.ExtendedErrors) > 0 {
+		var comments []string
+		for _, e := range a.ExtendedErrors {
+			comments = append(comments, fmt.Sprintf("EDE(%d): %s (%s)", e.InfoCode, e.Name, e.ExtraText))
+		}
+		resp["Comment"] = comments
+	}
+
+	for k, v := range a.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", "application/dns-json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func jsonRecords(records []Record) []jsonRecord {
+	out := make([]jsonRecord, 0, len(records))
+	for _, r := range records {
+		out = append(out, jsonRecord{Name: r.Name, Type: r.Type, TTL: r.TTL, Data: r.Data})
+	}
+	return out
+}
+
+func (s *Server) serveWire(w http.ResponseWriter, r *http.Request, query *dnsmessage.Message) {
+	if len(query.Questions) != 1 {
+		http.Error(w, "expected a single question", http.StatusBadRequest)
+		return
+	}
+	q := query.Questions[0]
+	a := s.answer(r.Host, q.Name.String(), int(q.Type))
+	if !a.wait(r.Context()) {
+		return
+	}
+
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 query.Header.ID,
+			Response:           true,
+			RecursionDesired:   query.Header.RecursionDesired,
+			RecursionAvailable: true,
+			AuthenticData:      a.AD,
+			CheckingDisabled:   query.Header.CheckingDisabled,
+			RCode:              dnsmessage.RCode(a.Status & 0x0f),
+		},
+		Questions: query.Questions,
+	}
+
+	var err error
+	if resp.Answers, err = wireRecords(a.Records); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if resp.Authorities, err = wireRecords(a.Authority); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Answer with an OPT record when the query had one, which is where EDE options go
+	for _, rr := range query.Additionals {
+		if rr.Header.Type != dnsmessage.TypeOPT {
+			continue
+		}
+		opt := &dnsmessage.OPTResource{}
+		for _, e := range a.ExtendedErrors {
+			data := append([]byte{byte(e.InfoCode >> 8), byte(e.InfoCode)}, e.ExtraText...)
+			opt.Options = append(opt.Options, dnsmessage.Option{Code: common.OptionExtendedError, Data: data})
+		}
+		var h dnsmessage.ResourceHeader
+		if err := h.SetEDNS0(4096, dnsmessage.RCode(a.Status), rr.Header.DNSSECAllowed()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Additionals = append(resp.Additionals, dnsmessage.Resource{Header: h, Body: opt})
+	}
+
+	b, err := resp.Pack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for k, v := range a.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", common.MediaTypeDNSMessage)
+	w.Write(b)
+}
+
+func wireRecords(records []Record) ([]dnsmessage.Resource, error) {
+	var out []dnsmessage.Resource
+	for _, r := range records {
+		name, err := dnsmessage.NewName(common.FQDN(r.Name))
+		if err != nil {
+			return nil, err
+		}
+		body, err := wireBody(r.Type, r.Data)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{
+				Name:  name,
+				Type:  dnsmessage.Type(r.Type),
+				Class: dnsmessage.ClassINET,
+				TTL:   uint32(r.TTL),
+			},
+			Body: body,
+		})
+	}
+	return out, nil
+}
+
+// wireBody converts presentation format record data into a dnsmessage resource body
+func wireBody(t int, data string) (dnsmessage.ResourceBody, error) {
+	rdata, err := common.ParseRData(t, data)
+	if err != nil {
+		return nil, err
+	}
+	return common.ResourceBody(t, rdata, data)
+}
@@ -0,0 +1,281 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dohdig"
+
+// labels identify a probe in every metric
+var labels = []string{"name", "type", "provider"}
+
+// Probe is a query to run against a provider, along with the answer it should return
+type Probe struct {
+	Name     string
+	Type     string
+	Provider string
+	Expect   string
+}
+
+func (p Probe) withDefaults() Probe {
+	if p.Type == "" {
+		p.Type = "A"
+	}
+	if p.Provider == "" {
+		p.Provider = "google"
+	}
+	return p
+}
+
+func (p Probe) labels() prometheus.Labels {
+	return prometheus.Labels{"name": p.Name, "type": p.Type, "provider": p.Provider}
+}
+
+// Result is the outcome of running a probe once
+type Result struct {
+	Probe    Probe
+	Response *common.QueryResponse
+	Duration time.Duration
+	Err      error
+}
+
+// Matches reports whether the response contains the expected answer, which is always the case
+// for probes that do not expect one
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (bool): true if the answer matches, false otherwise
+func (r Result) Matches() bool {
+	if r.Err != nil {
+		return false
+	}
+	return r.Probe.Expect == "" || r.Response.HasAnswer(r.Probe.Expect)
+}
+
+// Run queries the probe's provider once
+//
+// Arguments:
+//     p (Probe): The probe to run
+//
+// Returns:
+//     (Result): The outcome of the probe
+func Run(p Probe) Result {
+	p = p.withDefaults()
+	r := Result{Probe: p}
+
+	prov, err := provider.Get(p.Provider)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	start := time.Now()
+	r.Response, r.Err = prov.New(provider.Query{
+		Resource:     p.Name,
+		ResourceType: p.Type,
+		Padding:      common.PaddingBlock,
+	}).Do()
+	r.Duration = time.Since(start)
+	if r.Response != nil && r.Response.Request != nil {
+		r.Duration = r.Response.Request.Latency
+	}
+	return r
+}
+
+// Exporter runs a set of probes on a schedule and exposes their outcome as Prometheus metrics
+type Exporter struct {
+	probes   []Probe
+	interval time.Duration
+	registry *prometheus.Registry
+
+	duration   *prometheus.HistogramVec
+	responses  *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	mismatches *prometheus.CounterVec
+	success    *prometheus.GaugeVec
+	ad         *prometheus.GaugeVec
+}
+
+// New creates an Exporter for the provided probes
+//
+// Arguments:
+//     probes   ([]Probe):       The probes to run
+//     interval (time.Duration): How often every probe is run
+//
+// Returns:
+//     (*Exporter): A pointer to the exporter
+func New(probes []Probe, interval time.Duration) *Exporter {
+	e := &Exporter{
+		interval: interval,
+		registry: prometheus.NewRegistry(),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "probe_duration_seconds",
+			Help:      "How long the DoH query took.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, labels),
+		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "probe_responses_total",
+			Help:      "Responses received, by response code.",
+		}, append(labels, "rcode")),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "probe_errors_total",
+			Help:      "Queries that failed without a DNS response.",
+		}, labels),
+		mismatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "probe_answer_mismatches_total",
+			Help:      "Responses that did not contain the expected answer.",
+		}, labels),
+		success: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "probe_success",
+			Help:      "Whether the last run of the probe got a response with the expected answer.",
+		}, labels),
+		ad: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "probe_authenticated_data",
+			Help:      "Whether the last response had the AD (DNSSEC validated) bit set.",
+		}, labels),
+	}
+	e.registry.MustRegister(e.duration, e.responses, e.errors, e.mismatches, e.success, e.ad)
+
+	for _, p := range probes {
+		e.probes = append(e.probes, p.withDefaults())
+	}
+	return e
+}
+
+// Start runs every probe immediately and then once per interval until the context is done
+//
+// Arguments:
+//     ctx (context.Context): The context that stops the exporter
+//
+// Returns:
+//     None
+func (e *Exporter) Start(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.runAll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Exporter) runAll() {
+	var wg sync.WaitGroup
+	for _, p := range e.probes {
+		wg.Add(1)
+		go func(p Probe) {
+			defer wg.Done()
+			e.record(Run(p))
+		}(p)
+	}
+	wg.Wait()
+}
+
+func (e *Exporter) record(r Result) {
+	l := r.Probe.labels()
+	if r.Err != nil {
+		e.errors.With(l).Inc()
+		e.success.With(l).Set(0)
+		return
+	}
+
+	e.duration.With(l).Observe(r.Duration.Seconds())
+	rl := r.Probe.labels()
+	rl["rcode"] = r.Response.StatusName
+	e.responses.With(rl).Inc()
+	e.ad.With(l).Set(boolValue(r.Response.AD))
+	if !r.Matches() {
+		e.mismatches.With(l).Inc()
+	}
+	e.success.With(l).Set(boolValue(r.Matches() && r.Response.StatusCode == 0))
+}
+
+// Handler returns the HTTP handler serving /metrics for the scheduled probes and /probe for
+// on demand, blackbox exporter style probes
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (http.Handler): The handler
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/probe", ProbeHandler)
+	return mux
+}
+
+// ProbeHandler runs the probe described by the request's target, type, provider and expect
+// query parameters and responds with its outcome in the Prometheus exposition format
+//
+// Arguments:
+//     w (http.ResponseWriter): The response writer
+//     r (*http.Request):       The request
+//
+// Returns:
+//     None
+func ProbeHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "the target parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	res := Run(Probe{
+		Name:     target,
+		Type:     params.Get("type"),
+		Provider: params.Get("provider"),
+		Expect:   params.Get("expect"),
+	})
+
+	registry := prometheus.NewRegistry()
+	gauge := func(name, help string, v float64) {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help})
+		g.Set(v)
+		registry.MustRegister(g)
+	}
+
+	ok := res.Err == nil && res.Matches() && res.Response.StatusCode == 0
+	gauge("probe_success", "Whether the probe got a response with the expected answer.", boolValue(ok))
+	gauge("probe_duration_seconds", "How long the DoH query took.", res.Duration.Seconds())
+	if res.Err == nil {
+		gauge("probe_rcode", "The response code of the response.", float64(res.Response.StatusCode))
+		gauge("probe_authenticated_data", "Whether the response had the AD (DNSSEC validated) bit set.", boolValue(res.Response.AD))
+		gauge("probe_answer_count", "The number of records in the answer section.", float64(len(res.Response.Answer)))
+		if res.Probe.Expect != "" {
+			gauge("probe_answer_match", "Whether the response contained the expected answer.", boolValue(res.Matches()))
+		}
+	}
+
+	w.Header().Set("X-Probe-Result", strconv.FormatBool(ok))
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,138 @@
+package exporter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+)
+
+// get fetches path from the exporter's handler, returning the response and its body
+func get(t *testing.T, srv *httptest.Server, path string) (*http.Response, string) {
+	t.Helper()
+	resp, err := srv.Client().Get(srv.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", path, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s error reading the body = %v", path, err)
+	}
+	return resp, string(b)
+}
+
+func newTestExporter(t *testing.T, probes []Probe) (*Exporter, *httptest.Server) {
+	t.Helper()
+	s := dohtest.NewServer()
+	t.Cleanup(s.Close)
+	s.Handle("example.com", 1, dohtest.Answer{AD: true, Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "192.0.2.1"}}})
+
+	e := New(probes, time.Minute)
+	srv := httptest.NewServer(e.Handler())
+	t.Cleanup(srv.Close)
+	return e, srv
+}
+
+func TestProbe(t *testing.T) {
+	_, srv := newTestExporter(t, nil)
+
+	for _, tt := range []struct {
+		name, query, result string
+		want, absent        []string
+	}{
+		{
+			name:   "expected answer",
+			query:  "target=example.com&provider=cloudflare&expect=192.0.2.1",
+			result: "true",
+			want: []string{
+				"dohdig_probe_success 1\n",
+				"dohdig_probe_rcode 0\n",
+				"dohdig_probe_authenticated_data 1\n",
+				"dohdig_probe_answer_count 1\n",
+				"dohdig_probe_answer_match 1\n",
+			},
+		},
+		{
+			name:   "unexpected answer",
+			query:  "target=example.com&provider=cloudflare&expect=192.0.2.2",
+			result: "false",
+			want:   []string{"dohdig_probe_success 0\n", "dohdig_probe_answer_match 0\n"},
+		},
+		{
+			name:   "nxdomain",
+			query:  "target=missing.example.com&provider=cloudflare&type=AAAA",
+			result: "false",
+			want:   []string{"dohdig_probe_success 0\n", "dohdig_probe_rcode 3\n", "dohdig_probe_answer_count 0\n"},
+		},
+		{
+			name:   "unknown provider",
+			query:  "target=example.com&provider=bogus",
+			result: "false",
+			want:   []string{"dohdig_probe_success 0\n", "dohdig_probe_duration_seconds 0\n"},
+			absent: []string{"dohdig_probe_rcode", "dohdig_probe_answer_count"},
+		},
+	} {
+		resp, body := get(t, srv, "/probe?"+tt.query)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status = %s, want 200 OK", tt.name, resp.Status)
+		}
+		if got := resp.Header.Get("X-Probe-Result"); got != tt.result {
+			t.Errorf("%s: X-Probe-Result = %s, want %s", tt.name, got, tt.result)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(body, w) {
+				t.Errorf("%s: body does not contain %q:\n%s", tt.name, w, body)
+			}
+		}
+		for _, a := range tt.absent {
+			if strings.Contains(body, a) {
+				t.Errorf("%s: body contains %s without a response:\n%s", tt.name, a, body)
+			}
+		}
+	}
+
+	resp, _ := get(t, srv, "/probe?provider=cloudflare")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("without a target: status = %s, want 400 Bad Request", resp.Status)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	e, srv := newTestExporter(t, []Probe{
+		{Name: "example.com", Provider: "cloudflare", Expect: "192.0.2.1"},
+		{Name: "example.com", Type: "A", Provider: "google", Expect: "192.0.2.2"},
+		{Name: "missing.example.com", Type: "AAAA", Provider: "cloudflare"},
+		{Name: "example.com", Provider: "bogus"},
+	})
+	e.runAll()
+
+	_, body := get(t, srv, "/metrics")
+	for _, w := range []string{
+		`dohdig_probe_success{name="example.com",provider="cloudflare",type="A"} 1`,
+		`dohdig_probe_authenticated_data{name="example.com",provider="cloudflare",type="A"} 1`,
+		`dohdig_probe_responses_total{name="example.com",provider="cloudflare",rcode="NOERROR",type="A"} 1`,
+		`dohdig_probe_duration_seconds_count{name="example.com",provider="cloudflare",type="A"} 1`,
+		`dohdig_probe_success{name="example.com",provider="google",type="A"} 0`,
+		`dohdig_probe_answer_mismatches_total{name="example.com",provider="google",type="A"} 1`,
+		`dohdig_probe_success{name="missing.example.com",provider="cloudflare",type="AAAA"} 0`,
+		`dohdig_probe_responses_total{name="missing.example.com",provider="cloudflare",rcode="NXDOMAIN",type="AAAA"} 1`,
+		`dohdig_probe_success{name="example.com",provider="bogus",type="A"} 0`,
+		`dohdig_probe_errors_total{name="example.com",provider="bogus",type="A"} 1`,
+	} {
+		if !strings.Contains(body, w+"\n") {
+			t.Errorf("/metrics does not contain %s:\n%s", w, body)
+		}
+	}
+
+	// Every run adds to the counters
+	e.runAll()
+	_, body = get(t, srv, "/metrics")
+	if w := `dohdig_probe_errors_total{name="example.com",provider="bogus",type="A"} 2`; !strings.Contains(body, w+"\n") {
+		t.Errorf("/metrics after a second run does not contain %s:\n%s", w, body)
+	}
+}
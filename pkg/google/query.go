@@ -1,9 +1,10 @@
 package google
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/j4ng5y/dohdig/pkg/common"
 )
@@ -15,6 +16,7 @@ type QueryRequest struct {
 	ContentType             string
 	EDNSClientSubnet        string
 	RandomPadding           string
+	Padding                 string
 	DisableDNSSECValidation bool
 	ShowDNSSEC              bool
 }
@@ -28,33 +30,61 @@ type QueryRequest struct {
 //     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
 //     (error):                     An error if one exists, nil otherwise
 func (q QueryRequest) Do() (*common.QueryResponse, error) {
-	U := fmt.Sprintf(
-		"https://dns.google.com/resolve?name=%s&type=%s&ct=%s&edns_client_subnet=%s&cd=%v&do=%v",
-		q.Resource,
-		q.ResourceType,
-		q.ContentType,
-		q.EDNSClientSubnet,
-		q.DisableDNSSECValidation,
-		q.ShowDNSSEC)
-	u, err := url.Parse(U)
+	return q.DoContext(context.Background())
+}
+
+// DoContext runs the query, abandoning the HTTP request once ctx is done
+//
+// Arguments:
+//     ctx (context.Context): The context of the request
+//
+// Returns:
+//     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):                     An error if one exists, nil otherwise
+func (q QueryRequest) DoContext(ctx context.Context) (*common.QueryResponse, error) {
+	name, err := common.ToASCII(q.Resource)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing the provided url: %s, err: %w", q.Resource, err)
+		return nil, err
 	}
 
-	c := http.DefaultClient
-	r, err := c.Do(&http.Request{
+	v := url.Values{
+		"name":               []string{name},
+		"type":               []string{q.ResourceType},
+		"ct":                 []string{q.ContentType},
+		"edns_client_subnet": []string{q.EDNSClientSubnet},
+		"cd":                 []string{strconv.FormatBool(q.DisableDNSSECValidation)},
+		"do":                 []string{strconv.FormatBool(q.ShowDNSSEC)},
+		"random_padding":     []string{q.RandomPadding},
+	}
+	u := &url.URL{
+		Scheme:   "https",
+		Host:     "dns.google.com",
+		Path:     "/resolve",
+		RawQuery: v.Encode(),
+	}
+
+	// The random_padding parameter is ignored by the server, it only exists so that the
+	// length of the URL does not reveal the name being queried
+	if q.RandomPadding == "" {
+		n, err := common.PaddingLength(q.Padding, len(u.String()))
+		if err != nil {
+			return nil, err
+		}
+		v.Set("random_padding", common.RandomPadding(n))
+		if n == 0 {
+			v.Del("random_padding")
+		}
+		u.RawQuery = v.Encode()
+	}
+
+	resp, err := common.Exchange(ctx, &http.Request{
 		Method: http.MethodGet,
 		URL:    u,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error sending the HTTP request, err: %w", err)
-	}
-
-	resp := new(common.QueryResponse)
-	if err := resp.Unmarshal(r.Body); err != nil {
-		return nil, fmt.Errorf("error unmarshalling the response, err: %w", err)
+		return nil, err
 	}
 
-	resp.DetermineStatusMessage()
+	resp.Request.Padding = len(v.Get("random_padding"))
 	return resp, nil
 }
@@ -0,0 +1,95 @@
+package google
+
+import (
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+)
+
+func TestDo(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{
+		AD:      true,
+		Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "93.184.216.34"}},
+	})
+
+	resp, err := QueryRequest{
+		Resource:         "example.com",
+		ResourceType:     "A",
+		EDNSClientSubnet: "198.51.100.0/24",
+		ShowDNSSEC:       true,
+	}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	req := s.LastRequest()
+	if req.Host != "dns.google.com" || req.URL.Path != "/resolve" {
+		t.Errorf("request sent to %s%s, want dns.google.com/resolve", req.Host, req.URL.Path)
+	}
+	q := req.URL.Query()
+	for k, want := range map[string]string{
+		"name":               "example.com",
+		"type":               "A",
+		"edns_client_subnet": "198.51.100.0/24",
+		"cd":                 "false",
+		"do":                 "true",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if _, ok := q["random_padding"]; ok {
+		t.Errorf("random_padding = %q, want it left out without a padding policy", q.Get("random_padding"))
+	}
+
+	if !resp.AD {
+		t.Error("AD = false, want true")
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].Data != "93.184.216.34" {
+		t.Errorf("answer = %+v, want a single 93.184.216.34 record", resp.Answer)
+	}
+}
+
+func TestDoBlockPadding(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+
+	for _, name := range []string{"a.example", "a-much-longer-name.example.com"} {
+		resp, err := QueryRequest{Resource: name, ResourceType: "A", Padding: common.PaddingBlock}.Do()
+		if err != nil {
+			t.Fatalf("Do(%s) error = %v", name, err)
+		}
+		if resp.StatusCode != 3 {
+			t.Errorf("Do(%s) status = %d, want NXDOMAIN for an unscripted name", name, resp.StatusCode)
+		}
+
+		req := s.LastRequest()
+		u := "https://" + req.Host + req.URL.RequestURI()
+		if len(u)%common.PaddingBlockSize != 0 {
+			t.Errorf("Do(%s) url length = %d, want a multiple of %d", name, len(u), common.PaddingBlockSize)
+		}
+		if got, want := resp.Request.Padding, len(req.URL.Query().Get("random_padding")); got != want {
+			t.Errorf("Do(%s) reported padding = %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestDoExplicitPadding(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+
+	if _, err := (QueryRequest{
+		Resource:      "example.com",
+		ResourceType:  "A",
+		RandomPadding: "xxxx",
+		Padding:       common.PaddingBlock,
+	}).Do(); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if got := s.LastRequest().URL.Query().Get("random_padding"); got != "xxxx" {
+		t.Errorf("random_padding = %q, want the explicit xxxx", got)
+	}
+}
@@ -1,20 +1,33 @@
 package nextdns
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/wire"
 )
 
-// QueryRequest is the request needed to query dns.google.com
+// headerPrefix is the prefix of the response headers NextDNS uses to describe how a query was
+// handled by the profile, such as the reason it was blocked
+const headerPrefix = "X-Nextdns-"
+
+// QueryRequest is the request needed to query dns.nextdns.io
 type QueryRequest struct {
 	ID                      string
+	DeviceName              string
+	DeviceModel             string
 	Resource                string
 	ResourceType            string
+	EDNSClientSubnet        string
+	Padding                 string
 	DisableDNSSECValidation bool
 	ShowDNSSEC              bool
+	Wire                    bool
 }
 
 // Do runs the query
@@ -26,38 +39,99 @@ type QueryRequest struct {
 //     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
 //     (error):                     An error if one exists, nil otherwise
 func (q QueryRequest) Do() (*common.QueryResponse, error) {
-	U := fmt.Sprintf(
-		"https://dns.nextdns.io/%s?name=%s&type=%s&cd=%v&do=%v",
-		q.ID,
-		q.Resource,
-		q.ResourceType,
-		q.DisableDNSSECValidation,
-		q.ShowDNSSEC)
-
-	u, err := url.Parse(U)
+	return q.DoContext(context.Background())
+}
+
+// DoContext runs the query, abandoning the HTTP request once ctx is done
+//
+// Arguments:
+//     ctx (context.Context): The context of the request
+//
+// Returns:
+//     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):                     An error if one exists, nil otherwise
+func (q QueryRequest) DoContext(ctx context.Context) (*common.QueryResponse, error) {
+	if q.ID == "" {
+		return nil, fmt.Errorf("a NextDNS profile ID is required")
+	}
+
+	resp, err := q.do(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing the provided url: %s, err: %w", q.Resource, err)
+		return nil, err
 	}
 
-	c := http.DefaultClient
-	r, err := c.Do(&http.Request{
-		Method: http.MethodGet,
-		URL:    u,
-		Header: http.Header{
-			"accept": []string{
-				"application/dns-json",
-			},
-		},
-	})
+	ParseHeaders(resp)
+	return resp, nil
+}
+
+func (q QueryRequest) do(ctx context.Context) (*common.QueryResponse, error) {
+	// NextDNS identifies the device by the path segment that follows the profile ID
+	path, rawPath := "/"+q.ID, "/"+url.PathEscape(q.ID)
+	if q.DeviceName != "" {
+		path += "/" + q.DeviceName
+		rawPath += "/" + url.PathEscape(q.DeviceName)
+	}
+
+	header := http.Header{}
+	if q.DeviceModel != "" {
+		header.Set("X-Device-Model", q.DeviceModel)
+	}
+
+	if q.Wire {
+		return wire.QueryRequest{
+			URL:                     "https://dns.nextdns.io" + rawPath,
+			Header:                  header,
+			Resource:                q.Resource,
+			ResourceType:            q.ResourceType,
+			EDNSClientSubnet:        q.EDNSClientSubnet,
+			Padding:                 q.Padding,
+			DisableDNSSECValidation: q.DisableDNSSECValidation,
+			ShowDNSSEC:              q.ShowDNSSEC,
+		}.DoContext(ctx)
+	}
+
+	name, err := common.ToASCII(q.Resource)
 	if err != nil {
-		return nil, fmt.Errorf("error sending the HTTP request, err: %w", err)
+		return nil, err
 	}
 
-	resp := new(common.QueryResponse)
-	if err := resp.Unmarshal(r.Body); err != nil {
-		return nil, fmt.Errorf("error unmarshalling the response, err: %w", err)
+	u := &url.URL{
+		Scheme:  "https",
+		Host:    "dns.nextdns.io",
+		Path:    path,
+		RawPath: rawPath,
+		RawQuery: url.Values{
+			"name": []string{name},
+			"type": []string{q.ResourceType},
+			"cd":   []string{strconv.FormatBool(q.DisableDNSSECValidation)},
+			"do":   []string{strconv.FormatBool(q.ShowDNSSEC)},
+		}.Encode(),
 	}
 
-	resp.DetermineStatusMessage()
-	return resp, nil
+	header.Set("accept", "application/dns-json")
+	return common.Exchange(ctx, &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Header: header,
+	})
+}
+
+// ParseHeaders copies the NextDNS specific response headers into the response's provider info,
+// keyed by the header name without its X-Nextdns- prefix
+//
+// Arguments:
+//     resp (*pkg.common.QueryResponse): The response to annotate
+//
+// Returns:
+//     None
+func ParseHeaders(resp *common.QueryResponse) {
+	for k, v := range resp.Header {
+		if !strings.HasPrefix(http.CanonicalHeaderKey(k), headerPrefix) {
+			continue
+		}
+		if resp.ProviderInfo == nil {
+			resp.ProviderInfo = make(map[string]string)
+		}
+		resp.ProviderInfo[http.CanonicalHeaderKey(k)[len(headerPrefix):]] = strings.Join(v, ", ")
+	}
 }
@@ -0,0 +1,81 @@
+package nextdns
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+)
+
+func TestDo(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("ads.example.com", 1, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "ads.example.com.", Type: 1, TTL: 300, Data: "0.0.0.0"}},
+		Header:  http.Header{"X-Nextdns-Reasons": {"blocklist:oisd"}, "X-Nextdns-Status": {"blocked"}},
+	})
+
+	resp, err := QueryRequest{
+		ID:           "abc123",
+		DeviceName:   "My Laptop",
+		DeviceModel:  "laptop",
+		Resource:     "ads.example.com",
+		ResourceType: "A",
+	}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	req := s.LastRequest()
+	if req.Host != "dns.nextdns.io" || req.URL.EscapedPath() != "/abc123/My%20Laptop" {
+		t.Errorf("request sent to %s%s, want dns.nextdns.io/abc123/My%%20Laptop", req.Host, req.URL.EscapedPath())
+	}
+	if got := req.Header.Get("X-Device-Model"); got != "laptop" {
+		t.Errorf("X-Device-Model = %q, want laptop", got)
+	}
+	if got := req.Header.Get("Accept"); got != "application/dns-json" {
+		t.Errorf("Accept = %q, want application/dns-json", got)
+	}
+
+	if got := resp.ProviderInfo["Reasons"]; got != "blocklist:oisd" {
+		t.Errorf("ProviderInfo[Reasons] = %q, want blocklist:oisd", got)
+	}
+	if got := resp.ProviderInfo["Status"]; got != "blocked" {
+		t.Errorf("ProviderInfo[Status] = %q, want blocked", got)
+	}
+}
+
+func TestDoWire(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "93.184.216.34"}},
+		Header:  http.Header{"X-Nextdns-Status": {"ok"}},
+	})
+
+	resp, err := QueryRequest{ID: "abc123", Resource: "example.com", ResourceType: "A", Wire: true}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	req := s.LastRequest()
+	if req.URL.Path != "/abc123" || req.Message == nil {
+		t.Fatalf("request = %s %s, want a wire-format query to /abc123", req.Method, req.URL)
+	}
+	if got := req.Header.Get("Accept"); got != common.MediaTypeDNSMessage {
+		t.Errorf("Accept = %q, want %s", got, common.MediaTypeDNSMessage)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].Data != "93.184.216.34" {
+		t.Errorf("answer = %+v, want a single 93.184.216.34 record", resp.Answer)
+	}
+	if got := resp.ProviderInfo["Status"]; got != "ok" {
+		t.Errorf("ProviderInfo[Status] = %q, want ok", got)
+	}
+}
+
+func TestDoRequiresID(t *testing.T) {
+	if _, err := (QueryRequest{Resource: "example.com", ResourceType: "A"}).Do(); err == nil {
+		t.Error("Do() error = nil, want a missing profile ID error")
+	}
+}
@@ -1,9 +1,11 @@
 package nixnet
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/j4ng5y/dohdig/pkg/common"
 )
@@ -26,55 +28,52 @@ type QueryRequest struct {
 //     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
 //     (error):                     An error if one exists, nil otherwise
 func (q QueryRequest) Do() (*common.QueryResponse, error) {
-	var U string
+	return q.DoContext(context.Background())
+}
 
+// DoContext runs the query, abandoning the HTTP request once ctx is done
+//
+// Arguments:
+//     ctx (context.Context): The context of the request
+//
+// Returns:
+//     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):                     An error if one exists, nil otherwise
+func (q QueryRequest) DoContext(ctx context.Context) (*common.QueryResponse, error) {
+	var host string
 	switch q.ServerType {
 	case "uncensored":
-		U = fmt.Sprintf(
-			"https://uncensored.any.dns.nixnet.xyz/dns-query?name=%s&type=%s&cd=%v&do=%v",
-			q.Resource,
-			q.ResourceType,
-			q.DisableDNSSECValidation,
-			q.ShowDNSSEC)
+		host = "uncensored.any.dns.nixnet.xyz"
 	case "adblock":
-		U = fmt.Sprintf(
-			"https://adblock.any.dns.nixnet.xyz/dns-query?name=%s&type=%s&cd=%v&do=%v",
-			q.Resource,
-			q.ResourceType,
-			q.DisableDNSSECValidation,
-			q.ShowDNSSEC)
+		host = "adblock.any.dns.nixnet.xyz"
 	case "lasvegas":
-		U = fmt.Sprintf(
-			"https://uncensored.lv1.dns.nixnet.xyz/dns-query?name=%s&type=%s&cd=%v&do=%v",
-			q.Resource,
-			q.ResourceType,
-			q.DisableDNSSECValidation,
-			q.ShowDNSSEC)
+		host = "uncensored.lv1.dns.nixnet.xyz"
 	case "newyork":
-		U = fmt.Sprintf(
-			"https://uncensored.ny1.dns.nixnet.xyz/dns-query?name=%s&type=%s&cd=%v&do=%v",
-			q.Resource,
-			q.ResourceType,
-			q.DisableDNSSECValidation,
-			q.ShowDNSSEC)
+		host = "uncensored.ny1.dns.nixnet.xyz"
 	case "luxembourg":
-		U = fmt.Sprintf(
-			"https://uncensored.lux1.dns.nixnet.xyz/dns-query?name=%s&type=%s&cd=%v&do=%v",
-			q.Resource,
-			q.ResourceType,
-			q.DisableDNSSECValidation,
-			q.ShowDNSSEC)
+		host = "uncensored.lux1.dns.nixnet.xyz"
 	default:
 		return nil, fmt.Errorf("unsupported nixnet server type, %s", q.ServerType)
 	}
 
-	u, err := url.Parse(U)
+	name, err := common.ToASCII(q.Resource)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing the provided url: %s, err: %w", q.Resource, err)
+		return nil, err
 	}
 
-	c := http.DefaultClient
-	r, err := c.Do(&http.Request{
+	u := &url.URL{
+		Scheme: "https",
+		Host:   host,
+		Path:   "/dns-query",
+		RawQuery: url.Values{
+			"name": []string{name},
+			"type": []string{q.ResourceType},
+			"cd":   []string{strconv.FormatBool(q.DisableDNSSECValidation)},
+			"do":   []string{strconv.FormatBool(q.ShowDNSSEC)},
+		}.Encode(),
+	}
+
+	return common.Exchange(ctx, &http.Request{
 		Method: http.MethodGet,
 		URL:    u,
 		Header: http.Header{
@@ -83,15 +82,4 @@ func (q QueryRequest) Do() (*common.QueryResponse, error) {
 			},
 		},
 	})
-	if err != nil {
-		return nil, fmt.Errorf("error sending the HTTP request, err: %w", err)
-	}
-
-	resp := new(common.QueryResponse)
-	if err := resp.Unmarshal(r.Body); err != nil {
-		return nil, fmt.Errorf("error unmarshalling the response, err: %w", err)
-	}
-
-	resp.DetermineStatusMessage()
-	return resp, nil
 }
@@ -0,0 +1,45 @@
+package nixnet
+
+import (
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+)
+
+func TestDo(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 16, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "example.com.", Type: 16, TTL: 300, Data: `"v=spf1 " "-all"`}},
+	})
+
+	for serverType, host := range map[string]string{
+		"uncensored": "uncensored.any.dns.nixnet.xyz",
+		"adblock":    "adblock.any.dns.nixnet.xyz",
+		"lasvegas":   "uncensored.lv1.dns.nixnet.xyz",
+		"newyork":    "uncensored.ny1.dns.nixnet.xyz",
+		"luxembourg": "uncensored.lux1.dns.nixnet.xyz",
+	} {
+		resp, err := QueryRequest{ServerType: serverType, Resource: "example.com", ResourceType: "TXT"}.Do()
+		if err != nil {
+			t.Fatalf("Do(%s) error = %v", serverType, err)
+		}
+
+		req := s.LastRequest()
+		if req.Host != host || req.URL.Path != "/dns-query" {
+			t.Errorf("Do(%s) sent to %s%s, want %s/dns-query", serverType, req.Host, req.URL.Path, host)
+		}
+		if len(resp.Answer) != 1 {
+			t.Fatalf("Do(%s) answer = %+v, want a single record", serverType, resp.Answer)
+		}
+		if got := resp.Answer[0].RData; got == nil || got.String() != `"v=spf1 " "-all"` {
+			t.Errorf("Do(%s) rdata = %v, want the two character strings", serverType, got)
+		}
+	}
+}
+
+func TestDoUnsupportedServerType(t *testing.T) {
+	if _, err := (QueryRequest{ServerType: "tokyo", Resource: "example.com", ResourceType: "A"}).Do(); err == nil {
+		t.Error("Do() error = nil, want an unsupported server type error")
+	}
+}
@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"github.com/j4ng5y/dohdig/pkg/blahdns"
+	"github.com/j4ng5y/dohdig/pkg/cloudflare"
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/custom"
+	"github.com/j4ng5y/dohdig/pkg/google"
+	"github.com/j4ng5y/dohdig/pkg/nextdns"
+	"github.com/j4ng5y/dohdig/pkg/nixnet"
+	"github.com/j4ng5y/dohdig/pkg/securedns"
+	"github.com/j4ng5y/dohdig/pkg/snopyta"
+	"github.com/j4ng5y/dohdig/pkg/wire"
+)
+
+func init() {
+	mustRegister(Provider{
+		Name:             "google",
+		Location:         "Anycast",
+		Endpoint:         "https://dns.google.com/resolve",
+		WireEndpoint:     "https://dns.google/dns-query",
+		Bootstrap:        []string{"8.8.8.8", "8.8.4.4", "2001:4860:4860::8888", "2001:4860:4860::8844"},
+		JSONClientSubnet: true,
+		New: func(q Query) common.Do {
+			if q.Wire {
+				return wireRequest("https://dns.google/dns-query", q)
+			}
+			return google.QueryRequest{
+				Resource:                q.Resource,
+				ResourceType:            q.ResourceType,
+				ContentType:             q.ContentType,
+				EDNSClientSubnet:        q.EDNSClientSubnet,
+				RandomPadding:           q.RandomPadding,
+				Padding:                 q.Padding,
+				DisableDNSSECValidation: q.DisableDNSSECValidation,
+				ShowDNSSEC:              q.ShowDNSSEC,
+			}
+		},
+	})
+	mustRegister(Provider{
+		Name:      "cloudflare",
+		Location:  "Anycast",
+		Endpoint:  "https://cloudflare-dns.com/dns-query",
+		Bootstrap: []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111", "2606:4700:4700::1001"},
+		New: func(q Query) common.Do {
+			if q.Wire {
+				return wireRequest("https://cloudflare-dns.com/dns-query", q)
+			}
+			return cloudflare.QueryRequest{
+				Resource:                q.Resource,
+				ResourceType:            q.ResourceType,
+				DisableDNSSECValidation: q.DisableDNSSECValidation,
+				ShowDNSSEC:              q.ShowDNSSEC,
+			}
+		},
+	})
+	for _, server := range []struct {
+		country   string
+		location  string
+		bootstrap []string
+	}{
+		{"fi", "Helsinki, FI", []string{"95.216.212.177", "2a01:4f9:c010:43ce::1"}},
+		{"jp", "Tokyo, JP", []string{"139.162.112.47", "2400:8902::f03c:92ff:fe27:344b"}},
+		{"de", "Falkenstein, DE", []string{"159.69.198.101", "2a01:4f8:1c1c:6b4b::1"}},
+	} {
+		country := server.country
+		mustRegister(Provider{
+			Name:      "blahdns-" + country,
+			Location:  server.location,
+			Endpoint:  "https://doh-" + country + ".blahdns.com/dns-query",
+			Bootstrap: server.bootstrap,
+			New: func(q Query) common.Do {
+				if q.Wire {
+					return wireRequest("https://doh-"+country+".blahdns.com/dns-query", q)
+				}
+				return blahdns.QueryRequest{
+					Country:                 country,
+					Resource:                q.Resource,
+					ResourceType:            q.ResourceType,
+					DisableDNSSECValidation: q.DisableDNSSECValidation,
+					ShowDNSSEC:              q.ShowDNSSEC,
+				}
+			},
+		})
+	}
+	mustRegister(Provider{
+		Name:      "nextdns",
+		Location:  "Anycast",
+		Endpoint:  "https://dns.nextdns.io/",
+		Bootstrap: []string{"45.90.28.0", "45.90.30.0", "2a07:a8c0::", "2a07:a8c1::"},
+		New: func(q Query) common.Do {
+			return nextdns.QueryRequest{
+				ID:                      q.NextDNSID,
+				DeviceName:              q.NextDNSDeviceName,
+				DeviceModel:             q.NextDNSDeviceModel,
+				Resource:                q.Resource,
+				ResourceType:            q.ResourceType,
+				EDNSClientSubnet:        q.EDNSClientSubnet,
+				Padding:                 q.Padding,
+				DisableDNSSECValidation: q.DisableDNSSECValidation,
+				ShowDNSSEC:              q.ShowDNSSEC,
+				Wire:                    q.Wire,
+			}
+		},
+	})
+	for _, server := range []struct {
+		serverType string
+		host       string
+		location   string
+		bootstrap  []string
+	}{
+		{"uncensored", "uncensored.any.dns.nixnet.xyz", "Anycast", []string{"198.251.90.71", "2605:6400:20:13bf:df4e:db4e:fc6b:2c1e"}},
+		{"adblock", "adblock.any.dns.nixnet.xyz", "Anycast", []string{"198.251.90.89", "2605:6400:20:13bf:df4e:db4e:fc6b:7c2e"}},
+		{"lasvegas", "uncensored.lv1.dns.nixnet.xyz", "Las Vegas, US", []string{"198.251.90.114", "2605:6400:20:13bf:df4e:db4e:fc6b:1b9f"}},
+		{"newyork", "uncensored.ny1.dns.nixnet.xyz", "New York, US", []string{"209.141.34.95", "2605:6400:10:5bf:df4e:db4e:fc6b:7e7b"}},
+		{"luxembourg", "uncensored.lux1.dns.nixnet.xyz", "Luxembourg, LU", []string{"104.244.78.122", "2605:6400:30:fdb3:df4e:db4e:fc6b:5a11"}},
+	} {
+		serverType := server.serverType
+		endpoint := "https://" + server.host + "/dns-query"
+		mustRegister(Provider{
+			Name:      "nixnet-" + serverType,
+			Location:  server.location,
+			Endpoint:  endpoint,
+			Bootstrap: server.bootstrap,
+			New: func(q Query) common.Do {
+				if q.Wire {
+					return wireRequest(endpoint, q)
+				}
+				return nixnet.QueryRequest{
+					ServerType:              serverType,
+					Resource:                q.Resource,
+					ResourceType:            q.ResourceType,
+					DisableDNSSECValidation: q.DisableDNSSECValidation,
+					ShowDNSSEC:              q.ShowDNSSEC,
+				}
+			},
+		})
+	}
+	mustRegister(Provider{
+		Name:      "securedns",
+		Location:  "Amsterdam, NL",
+		Endpoint:  "https://doh.securedns.eu/dns-query",
+		Bootstrap: []string{"146.185.167.43", "2a03:b0c0:0:1010::e9a:3001"},
+		New: func(q Query) common.Do {
+			if q.Wire {
+				return wireRequest("https://doh.securedns.eu/dns-query", q)
+			}
+			return securedns.QueryRequest{
+				Resource:                q.Resource,
+				ResourceType:            q.ResourceType,
+				DisableDNSSECValidation: q.DisableDNSSECValidation,
+				ShowDNSSEC:              q.ShowDNSSEC,
+			}
+		},
+	})
+	mustRegister(Provider{
+		Name:      "snopyta",
+		Location:  "Helsinki, FI",
+		Endpoint:  "https://fi.doh.dns.snopyta.org/dns-query",
+		Bootstrap: []string{"95.216.24.230", "2a01:4f9:2a:1919::9301"},
+		New: func(q Query) common.Do {
+			if q.Wire {
+				return wireRequest("https://fi.doh.dns.snopyta.org/dns-query", q)
+			}
+			return snopyta.QueryRequest{
+				Resource:                q.Resource,
+				ResourceType:            q.ResourceType,
+				DisableDNSSECValidation: q.DisableDNSSECValidation,
+				ShowDNSSEC:              q.ShowDNSSEC,
+			}
+		},
+	})
+}
+
+// Custom describes a user defined DoH endpoint
+type Custom struct {
+	Name        string
+	Description string
+	Location    string
+	URL         string
+	Bootstrap   []string
+	Pins        []string
+
+	// Wire is set when the endpoint only speaks RFC 8484 wire-format messages
+	Wire bool
+}
+
+// RegisterCustom adds a user defined DoH endpoint to the registry
+//
+// Arguments:
+//     c (Custom): The endpoint to register
+//
+// Returns:
+//     (error): An error if the bootstrap addresses or pins are invalid, nil otherwise
+func RegisterCustom(c Custom) error {
+	return Register(Provider{
+		Name:        c.Name,
+		Description: c.Description,
+		Location:    c.Location,
+		Endpoint:    c.URL,
+		Bootstrap:   c.Bootstrap,
+		Pins:        c.Pins,
+		New: func(q Query) common.Do {
+			if q.Wire || c.Wire {
+				return wireRequest(c.URL, q)
+			}
+			return custom.QueryRequest{
+				URL:                     c.URL,
+				Resource:                q.Resource,
+				ResourceType:            q.ResourceType,
+				DisableDNSSECValidation: q.DisableDNSSECValidation,
+				ShowDNSSEC:              q.ShowDNSSEC,
+			}
+		},
+	})
+}
+
+func wireRequest(endpoint string, q Query) common.Do {
+	return wire.QueryRequest{
+		URL:                     endpoint,
+		Resource:                q.Resource,
+		ResourceType:            q.ResourceType,
+		EDNSClientSubnet:        q.EDNSClientSubnet,
+		Padding:                 q.Padding,
+		DisableDNSSECValidation: q.DisableDNSSECValidation,
+		ShowDNSSEC:              q.ShowDNSSEC,
+	}
+}
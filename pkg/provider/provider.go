@@ -0,0 +1,296 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+)
+
+// Query holds the provider agnostic options for a single lookup
+type Query struct {
+	Resource                string
+	ResourceType            string
+	ContentType             string
+	EDNSClientSubnet        string
+	RandomPadding           string
+	Padding                 string
+	DisableDNSSECValidation bool
+	ShowDNSSEC              bool
+	NextDNSID               string
+	NextDNSDeviceName       string
+	NextDNSDeviceModel      string
+	Wire                    bool
+}
+
+// Provider describes a DoH provider that dohdig knows how to query
+type Provider struct {
+	Name         string
+	Description  string
+	Location     string
+	Endpoint     string
+	WireEndpoint string
+	Bootstrap    []string
+	Pins         []string
+
+	// JSONClientSubnet is set when the provider's JSON API honours the EDNS Client Subnet,
+	// every other provider needs wire-format queries to send one
+	JSONClientSubnet bool
+
+	New func(q Query) common.Do
+}
+
+// Host returns the host name of the provider's endpoint
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (string): The host name, or "" if the endpoint is not a valid URL
+func (p Provider) Host() string {
+	return hostname(p.Endpoint)
+}
+
+// Hosts returns the host names of the provider's JSON and wire-format endpoints
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     ([]string): The distinct host names
+func (p Provider) Hosts() []string {
+	hosts := []string{p.Host()}
+	if h := hostname(p.WireEndpoint); h != "" && h != hosts[0] {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// WireURL returns the endpoint that accepts RFC 8484 wire-format queries
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (string): The wire-format endpoint, which is the main endpoint unless it only serves JSON
+func (p Provider) WireURL() string {
+	if p.WireEndpoint != "" {
+		return p.WireEndpoint
+	}
+	return p.Endpoint
+}
+
+func hostname(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+var registry []Provider
+
+// Register adds a provider to the registry, replacing any existing provider with the same name,
+// and configures the shared client with its bootstrap addresses and certificate pins
+//
+// Arguments:
+//     p (Provider): The provider to register
+//
+// Returns:
+//     (error): An error if the provider's bootstrap addresses or pins are invalid, nil otherwise
+func Register(p Provider) error {
+	for _, host := range p.Hosts() {
+		if err := common.SetBootstrap(host, p.Bootstrap); err != nil {
+			return err
+		}
+		if err := common.SetPins(host, p.Pins); err != nil {
+			return err
+		}
+	}
+
+	for i := range registry {
+		if registry[i].Name == p.Name {
+			registry[i] = p
+			return nil
+		}
+	}
+	registry = append(registry, p)
+	return nil
+}
+
+func mustRegister(p Provider) {
+	if err := Register(p); err != nil {
+		panic(err)
+	}
+}
+
+// Get looks up a registered provider by name
+//
+// Arguments:
+//     name (string): The name of the provider
+//
+// Returns:
+//     (Provider): The registered provider
+//     (error):    An error if the provider is not registered, nil otherwise
+func Get(name string) (Provider, error) {
+	for _, p := range registry {
+		if p.Name == name {
+			return p.observed(), nil
+		}
+	}
+	return Provider{}, fmt.Errorf("%s is an unsuppored provider", name)
+}
+
+// All returns every registered provider in registration order
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     ([]Provider): The registered providers
+func All() []Provider {
+	all := make([]Provider, 0, len(registry))
+	for _, p := range registry {
+		all = append(all, p.observed())
+	}
+	return all
+}
+
+// Names returns the names of every registered provider in registration order
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     ([]string): The registered provider names
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for _, p := range registry {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Event describes a lookup made through a registered provider, once it has completed
+type Event struct {
+	Time     time.Time
+	Provider Provider
+	Query    Query
+	Response *common.QueryResponse
+	Latency  time.Duration
+	Err      error
+}
+
+// Endpoint returns the endpoint the lookup was sent to
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (string): The wire-format endpoint for wire-format queries, the JSON endpoint otherwise
+func (e Event) Endpoint() string {
+	if e.Query.Wire {
+		return e.Provider.WireURL()
+	}
+	return e.Provider.Endpoint
+}
+
+// observer wraps an observing function, whose pointer identifies it for removal as functions
+// cannot be compared
+type observer struct {
+	fn func(Event)
+}
+
+var observers = struct {
+	sync.RWMutex
+	list []*observer
+}{}
+
+// Observe registers a function that is called after every lookup made through the requests of
+// the providers returned by Get and All, such as an audit log
+//
+// Arguments:
+//     fn (func(Event)): The function to call, which must be safe to call concurrently
+//
+// Returns:
+//     (func()): A function that removes the observer, after which fn is no longer called, such as
+//               before closing what it writes to
+func Observe(fn func(Event)) func() {
+	o := &observer{fn: fn}
+	observers.Lock()
+	defer observers.Unlock()
+	observers.list = append(observers.list, o)
+
+	return func() {
+		observers.Lock()
+		defer observers.Unlock()
+		for i, v := range observers.list {
+			if v == o {
+				observers.list = append(observers.list[:i:i], observers.list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// observed returns a copy of the provider whose requests report every lookup to the observers
+func (p Provider) observed() Provider {
+	newRequest := p.New
+	p.New = func(q Query) common.Do {
+		return observedRequest{provider: p, query: q, req: newRequest(q)}
+	}
+	return p
+}
+
+type observedRequest struct {
+	provider Provider
+	query    Query
+	req      common.Do
+}
+
+// Do runs the query and passes its outcome to the observers
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):                     An error if one exists, nil otherwise
+func (o observedRequest) Do() (*common.QueryResponse, error) {
+	return o.DoContext(context.Background())
+}
+
+// DoContext runs the query, abandoning it once ctx is done, and passes its outcome to the
+// observers
+//
+// Arguments:
+//     ctx (context.Context): The context of the request
+//
+// Returns:
+//     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):                     An error if one exists, nil otherwise
+func (o observedRequest) DoContext(ctx context.Context) (*common.QueryResponse, error) {
+	start := time.Now()
+	resp, err := o.req.DoContext(ctx)
+
+	observers.RLock()
+	defer observers.RUnlock()
+	if len(observers.list) == 0 {
+		return resp, err
+	}
+	e := Event{
+		Time:     start,
+		Provider: o.provider,
+		Query:    o.query,
+		Response: resp,
+		Latency:  time.Since(start),
+		Err:      err,
+	}
+	for _, obs := range observers.list {
+		obs.fn(e)
+	}
+	return resp, err
+}
@@ -0,0 +1,315 @@
+// Package report gathers the records that make up the overview of a domain, its addresses, name
+// servers, mail and policy records, and points out the common mistakes they reveal
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+// Record is a record of a section, with its data in presentation format
+type Record struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TTL  int    `json:"ttl"`
+	Data string `json:"data"`
+}
+
+// Section is the answer to one of the queries the report is made of
+type Section struct {
+	Title   string   `json:"title"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Status  string   `json:"status,omitempty"`
+	AD      bool     `json:"ad"`
+	Records []Record `json:"records"`
+	Error   string   `json:"error,omitempty"`
+
+	rdata []common.RData
+}
+
+// Data returns the data of the records of the section's type, leaving out the aliases that led
+// to them
+func (s Section) Data() []string {
+	var data []string
+	for _, r := range s.Records {
+		if r.Type == s.Type {
+			data = append(data, r.Data)
+		}
+	}
+	return data
+}
+
+// MailExchanger is an MX target along with the addresses it resolves to
+type MailExchanger struct {
+	Preference int      `json:"preference"`
+	Host       string   `json:"host"`
+	Addresses  []string `json:"addresses"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Report is the overview of a domain
+type Report struct {
+	Domain         string          `json:"domain"`
+	Provider       string          `json:"provider"`
+	Sections       []Section       `json:"sections"`
+	MailExchangers []MailExchanger `json:"mail_exchangers"`
+	Warnings       []string        `json:"warnings"`
+}
+
+// queries are the sections of a report, the names being relative to the domain
+var queries = []struct {
+	title, prefix, t string
+}{
+	{"Apex A", "", "A"},
+	{"Apex AAAA", "", "AAAA"},
+	{"www A", "www.", "A"},
+	{"www AAAA", "www.", "AAAA"},
+	{"NS", "", "NS"},
+	{"SOA", "", "SOA"},
+	{"MX", "", "MX"},
+	{"TXT", "", "TXT"},
+	{"CAA", "", "CAA"},
+	{"DMARC", "_dmarc.", "TXT"},
+	{"HTTPS", "", "HTTPS"},
+}
+
+// Run queries every section of the report in parallel through p, then resolves the MX targets
+// and evaluates the warnings
+//
+// Arguments:
+//     p      (pkg.provider.Provider): The provider to query
+//     base   (pkg.provider.Query):    The options of every query, the name and type are replaced
+//     domain (string):                The domain to report on
+//
+// Returns:
+//     (*Report): A pointer to the report, failed queries are recorded in their section
+func Run(p provider.Provider, base provider.Query, domain string) *Report {
+	domain = strings.TrimSuffix(domain, ".")
+	r := &Report{
+		Domain:         domain,
+		Provider:       p.Name,
+		Sections:       make([]Section, len(queries)),
+		MailExchangers: []MailExchanger{},
+		Warnings:       []string{},
+	}
+
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, title, name, t string) {
+			defer wg.Done()
+			r.Sections[i] = lookup(p, base, title, name, t)
+		}(i, q.title, q.prefix+domain, q.t)
+	}
+	wg.Wait()
+
+	r.resolveMailExchangers(p, base)
+	r.Warnings = append(r.Warnings, Evaluate(r)...)
+	return r
+}
+
+// lookup runs the query of a single section
+func lookup(p provider.Provider, base provider.Query, title, name, t string) Section {
+	s := Section{Title: title, Name: name, Type: t, Records: []Record{}}
+	q := base
+	q.Resource, q.ResourceType = name, t
+	resp, err := p.New(q).Do()
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+
+	s.Status = resp.StatusName
+	s.AD = resp.AD
+	for _, a := range resp.Answer {
+		a.DetermineTypeNameAndMeaning()
+		s.Records = append(s.Records, Record{Name: a.Name, Type: a.TypeName, TTL: a.TTL, Data: a.Data})
+		if a.TypeName == t {
+			s.rdata = append(s.rdata, a.RData)
+		}
+	}
+	return s
+}
+
+// Section returns the section with the given title, or nil if the report has none
+//
+// Arguments:
+//     title (string): The title of the section
+//
+// Returns:
+//     (*Section): A pointer to the section, or nil
+func (r *Report) Section(title string) *Section {
+	for i := range r.Sections {
+		if r.Sections[i].Title == title {
+			return &r.Sections[i]
+		}
+	}
+	return nil
+}
+
+// resolveMailExchangers looks up the addresses of every MX target in parallel, in order of
+// preference, skipping the null MX of domains that accept no mail
+func (r *Report) resolveMailExchangers(p provider.Provider, base provider.Query) {
+	mx := r.Section("MX")
+	if mx == nil {
+		return
+	}
+	for _, d := range mx.rdata {
+		// A null MX (RFC 7505) says the domain accepts no mail, so it has no exchangers to resolve
+		m, ok := d.(common.MXData)
+		if !ok || m.Host == "." || m.Host == "" {
+			continue
+		}
+		r.MailExchangers = append(r.MailExchangers, MailExchanger{Preference: m.Preference, Host: m.Host, Addresses: []string{}})
+	}
+	sort.SliceStable(r.MailExchangers, func(i, j int) bool {
+		return r.MailExchangers[i].Preference < r.MailExchangers[j].Preference
+	})
+
+	var wg sync.WaitGroup
+	for i := range r.MailExchangers {
+		wg.Add(1)
+		go func(m *MailExchanger) {
+			defer wg.Done()
+			var errs []string
+			for _, t := range []string{"A", "AAAA"} {
+				s := lookup(p, base, "", m.Host, t)
+				if s.Error != "" {
+					errs = append(errs, s.Error)
+					continue
+				}
+				m.Addresses = append(m.Addresses, s.Data()...)
+			}
+			m.Error = strings.Join(errs, "; ")
+		}(&r.MailExchangers[i])
+	}
+	wg.Wait()
+}
+
+// Evaluate returns the warnings the records of a report give rise to
+//
+// Arguments:
+//     r (*Report): The report to evaluate
+//
+// Returns:
+//     ([]string): The warnings, in the order of the sections they concern
+func Evaluate(r *Report) []string {
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	for _, s := range r.Sections {
+		switch {
+		case s.Error != "":
+			warn("the %s query for %s failed: %s", s.Type, s.Name, s.Error)
+		case s.Status != "NOERROR" && s.Status != "NXDOMAIN":
+			warn("the %s query for %s returned %s", s.Type, s.Name, s.Status)
+		}
+	}
+	if s := r.Section("SOA"); s != nil && s.Status == "NXDOMAIN" {
+		warn("%s does not exist", r.Domain)
+		return warnings
+	}
+
+	apexA, apexAAAA := r.data("Apex A"), r.data("Apex AAAA")
+	switch {
+	case len(apexA) == 0 && len(apexAAAA) == 0:
+		warn("the apex has no address records")
+	case len(apexAAAA) == 0:
+		warn("the apex has no AAAA records, so it cannot be reached over IPv6")
+	}
+	if len(r.data("www A")) == 0 && len(r.data("www AAAA")) == 0 {
+		warn("www.%s does not resolve to an address", r.Domain)
+	}
+
+	switch ns := r.data("NS"); len(ns) {
+	case 0:
+		warn("the apex has no NS records")
+	case 1:
+		warn("the apex has a single NS record, at least two are needed for redundancy")
+	}
+	if len(r.data("SOA")) == 0 {
+		warn("the apex has no SOA record")
+	}
+	if s := r.Section("SOA"); s != nil && s.Error == "" && !s.AD {
+		warn("the answers are not DNSSEC validated")
+	}
+
+	if len(r.data("MX")) == 0 {
+		warn("the apex has no MX records, so mail is delivered to its address records")
+	}
+	for _, m := range r.MailExchangers {
+		if len(m.Addresses) == 0 {
+			warn("the mail exchanger %s does not resolve to an address", m.Host)
+		}
+	}
+
+	var spf int
+	for _, d := range r.rdata("TXT") {
+		if t, ok := d.(common.TXTData); ok && strings.HasPrefix(strings.ToLower(t.Joined()), "v=spf1") {
+			spf++
+		}
+	}
+	switch {
+	case spf == 0:
+		warn("the apex has no SPF record")
+	case spf > 1:
+		warn("the apex has %d SPF records, which makes SPF evaluation fail", spf)
+	}
+
+	var dmarc []string
+	for _, d := range r.rdata("DMARC") {
+		if t, ok := d.(common.TXTData); ok && strings.HasPrefix(strings.ToLower(t.Joined()), "v=dmarc1") {
+			dmarc = append(dmarc, t.Joined())
+		}
+	}
+	switch {
+	case len(dmarc) == 0:
+		warn("_dmarc.%s has no DMARC record", r.Domain)
+	case len(dmarc) > 1:
+		warn("_dmarc.%s has %d DMARC records, which makes receivers ignore them", r.Domain, len(dmarc))
+	case dmarcPolicy(dmarc[0]) == "none":
+		warn("the DMARC policy is none, so failing mail is still delivered")
+	}
+
+	if len(r.data("CAA")) == 0 {
+		warn("the apex has no CAA records, so any certificate authority may issue for it")
+	}
+	return warnings
+}
+
+// data returns the record data of the section with the given title
+func (r *Report) data(title string) []string {
+	s := r.Section(title)
+	if s == nil {
+		return nil
+	}
+	return s.Data()
+}
+
+// rdata returns the typed record data of the section with the given title
+func (r *Report) rdata(title string) []common.RData {
+	s := r.Section(title)
+	if s == nil {
+		return nil
+	}
+	return s.rdata
+}
+
+// dmarcPolicy returns the value of the p tag of a DMARC record
+func dmarcPolicy(record string) string {
+	for _, tag := range strings.Split(record, ";") {
+		kv := strings.SplitN(strings.TrimSpace(tag), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), "p") {
+			return strings.ToLower(strings.TrimSpace(kv[1]))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+func record(name string, t int, data string) []dohtest.Record {
+	return []dohtest.Record{{Name: name, Type: t, TTL: 300, Data: data}}
+}
+
+func run(t *testing.T, wire bool) *Report {
+	t.Helper()
+	p, err := provider.Get("cloudflare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Run(p, provider.Query{ShowDNSSEC: true, Wire: wire}, "example.com.")
+}
+
+func TestRun(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{Records: record("example.com.", 1, "192.0.2.1")})
+	s.Handle("example.com", 28, dohtest.Answer{Records: record("example.com.", 28, "2001:db8::1")})
+	s.Handle("www.example.com", 1, dohtest.Answer{Records: []dohtest.Record{
+		{Name: "www.example.com.", Type: 5, TTL: 300, Data: "example.com."},
+		{Name: "example.com.", Type: 1, TTL: 300, Data: "192.0.2.1"},
+	}})
+	s.Handle("example.com", 2, dohtest.Answer{Records: []dohtest.Record{
+		{Name: "example.com.", Type: 2, TTL: 300, Data: "ns1.example.net."},
+		{Name: "example.com.", Type: 2, TTL: 300, Data: "ns2.example.net."},
+	}})
+	s.Handle("example.com", 6, dohtest.Answer{AD: true, Records: record("example.com.", 6, "ns1.example.net. hostmaster.example.com. 7 7200 3600 1209600 60")})
+	s.Handle("example.com", 15, dohtest.Answer{Records: []dohtest.Record{
+		{Name: "example.com.", Type: 15, TTL: 300, Data: "20 mx2.example.com."},
+		{Name: "example.com.", Type: 15, TTL: 300, Data: "10 mx1.example.com."},
+	}})
+	s.Handle("mx1.example.com", 1, dohtest.Answer{Records: record("mx1.example.com.", 1, "192.0.2.25")})
+	s.Handle("mx1.example.com", 28, dohtest.Answer{Records: record("mx1.example.com.", 28, "2001:db8::25")})
+	s.Handle("mx2.example.com", 1, dohtest.Answer{Records: record("mx2.example.com.", 1, "192.0.2.26")})
+	s.Handle("example.com", 16, dohtest.Answer{Records: record("example.com.", 16, `"v=spf1 mx -all"`)})
+	s.Handle("_dmarc.example.com", 16, dohtest.Answer{Records: record("_dmarc.example.com.", 16, `"v=DMARC1; p=reject"`)})
+	s.Handle("example.com", 257, dohtest.Answer{Records: record("example.com.", 257, `0 issue "letsencrypt.org"`)})
+
+	for _, wire := range []bool{false, true} {
+		r := run(t, wire)
+		if r.Domain != "example.com" || r.Provider != "cloudflare" {
+			t.Errorf("report of %s by %s, want example.com by cloudflare", r.Domain, r.Provider)
+		}
+		if len(r.Warnings) != 0 {
+			t.Errorf("wire=%v: warnings = %q, want none", wire, r.Warnings)
+		}
+		if got := r.Section("www A").Data(); !reflect.DeepEqual(got, []string{"192.0.2.1"}) {
+			t.Errorf("www A data = %v, want [192.0.2.1]", got)
+		}
+		if got := len(r.Section("www A").Records); got != 2 {
+			t.Errorf("www A has %d records, want the CNAME and the A record", got)
+		}
+
+		want := []MailExchanger{
+			{Preference: 10, Host: "mx1.example.com.", Addresses: []string{"192.0.2.25", "2001:db8::25"}},
+			{Preference: 20, Host: "mx2.example.com.", Addresses: []string{"192.0.2.26"}},
+		}
+		if !reflect.DeepEqual(r.MailExchangers, want) {
+			t.Errorf("wire=%v: mail exchangers = %+v, want %+v", wire, r.MailExchangers, want)
+		}
+	}
+}
+
+func TestRunWarnings(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{Records: record("example.com.", 1, "192.0.2.1")})
+	s.Handle("example.com", 2, dohtest.Answer{Records: record("example.com.", 2, "ns1.example.net.")})
+	s.Handle("example.com", 6, dohtest.Answer{Records: record("example.com.", 6, "ns1.example.net. hostmaster.example.com. 7 7200 3600 1209600 60")})
+	s.Handle("example.com", 15, dohtest.Answer{Records: record("example.com.", 15, "10 mail.example.com.")})
+	s.Handle("example.com", 16, dohtest.Answer{Records: []dohtest.Record{
+		{Name: "example.com.", Type: 16, TTL: 300, Data: `"v=spf1 -all"`},
+		{Name: "example.com.", Type: 16, TTL: 300, Data: `"v=spf1 mx -all"`},
+	}})
+	s.Handle("_dmarc.example.com", 16, dohtest.Answer{Records: record("_dmarc.example.com.", 16, `"v=DMARC1; p=none"`)})
+	s.Handle("example.com", 65, dohtest.Answer{Status: 2})
+
+	r := run(t, false)
+	want := []string{
+		"the HTTPS query for example.com returned SERVFAIL",
+		"the apex has no AAAA records, so it cannot be reached over IPv6",
+		"www.example.com does not resolve to an address",
+		"the apex has a single NS record, at least two are needed for redundancy",
+		"the answers are not DNSSEC validated",
+		"the mail exchanger mail.example.com. does not resolve to an address",
+		"the apex has 2 SPF records, which makes SPF evaluation fail",
+		"the DMARC policy is none, so failing mail is still delivered",
+		"the apex has no CAA records, so any certificate authority may issue for it",
+	}
+	if !reflect.DeepEqual(r.Warnings, want) {
+		t.Errorf("warnings =\n%q\nwant\n%q", r.Warnings, want)
+	}
+}
+
+func TestRunMissingDomain(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+
+	r := run(t, false)
+	if want := []string{"example.com does not exist"}; !reflect.DeepEqual(r.Warnings, want) {
+		t.Errorf("warnings = %q, want %q", r.Warnings, want)
+	}
+}
+
+func TestNullMX(t *testing.T) {
+	r := &Report{Domain: "example.com", Sections: []Section{{
+		Title:   "MX",
+		Type:    "MX",
+		Status:  "NOERROR",
+		Records: []Record{{Type: "MX", Data: "0 ."}},
+	}}}
+	for _, w := range Evaluate(r) {
+		if w == "the apex has no MX records, so mail is delivered to its address records" {
+			t.Errorf("a null MX was reported as missing")
+		}
+	}
+}
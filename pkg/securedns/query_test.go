@@ -0,0 +1,41 @@
+package securedns
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+)
+
+func TestDo(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 15, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "example.com.", Type: 15, TTL: 300, Data: "10 mail.example.com."}},
+	})
+
+	resp, err := QueryRequest{Resource: "example.com", ResourceType: "MX", DisableDNSSECValidation: true}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	req := s.LastRequest()
+	if req.Host != "doh.securedns.eu" || req.URL.Path != "/dns-query" {
+		t.Errorf("request sent to %s%s, want doh.securedns.eu/dns-query", req.Host, req.URL.Path)
+	}
+	want := url.Values{"name": {"example.com"}, "type": {"MX"}, "cd": {"true"}, "do": {"false"}}
+	if got := req.URL.Query(); !reflect.DeepEqual(got, want) {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+	if got := req.Header.Get("Accept"); got != "application/dns-json" {
+		t.Errorf("Accept = %q, want application/dns-json", got)
+	}
+
+	if len(resp.Answer) != 1 {
+		t.Fatalf("answer = %+v, want a single record", resp.Answer)
+	}
+	if got := resp.Answer[0].RData; got == nil || got.String() != "10 mail.example.com." {
+		t.Errorf("rdata = %v, want 10 mail.example.com.", got)
+	}
+}
@@ -0,0 +1,272 @@
+package wire
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// QueryRequest is the request needed to query any RFC 8484 endpoint with wire-format messages
+type QueryRequest struct {
+	URL                     string
+	Header                  http.Header
+	Resource                string
+	ResourceType            string
+	EDNSClientSubnet        string
+	Padding                 string
+	DisableDNSSECValidation bool
+	ShowDNSSEC              bool
+}
+
+// Do runs the query
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):                     An error if one exists, nil otherwise
+func (q QueryRequest) Do() (*common.QueryResponse, error) {
+	return q.DoContext(context.Background())
+}
+
+// DoContext runs the query, abandoning the HTTP request once ctx is done
+//
+// Arguments:
+//     ctx (context.Context): The context of the request
+//
+// Returns:
+//     (*pkg.common.QueryResponse): A pointer to the query response, or nil if an error occurred
+//     (error):                     An error if one exists, nil otherwise
+func (q QueryRequest) DoContext(ctx context.Context) (*common.QueryResponse, error) {
+	msg, padding, err := q.pack()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(q.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the provided url: %s, err: %w", q.URL, err)
+	}
+
+	v := u.Query()
+	v.Set("dns", base64.RawURLEncoding.EncodeToString(msg))
+	u.RawQuery = v.Encode()
+
+	header := http.Header{}
+	for k, v := range q.Header {
+		header[k] = v
+	}
+	header.Set("accept", common.MediaTypeDNSMessage)
+
+	resp, err := common.Exchange(ctx, &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Header: header,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Request.MessageSize = len(msg)
+	resp.Request.Padding = padding
+	return resp, nil
+}
+
+// Forward sends an already packed query message to an RFC 8484 endpoint and returns the packed
+// response. The message ID is sent as 0, as RFC 8484 recommends for cache friendliness, and
+// restored in the response
+//
+// Arguments:
+//     ctx      (context.Context): The context of the request
+//     endpoint (string):          The URL of the DoH endpoint
+//     msg      ([]byte):          The packed DNS query
+//
+// Returns:
+//     ([]byte): The packed DNS response, or nil if an error occurred
+//     (error):  An error if one exists, nil otherwise
+func Forward(ctx context.Context, endpoint string, msg []byte) ([]byte, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("the DNS message is too short: %d bytes", len(msg))
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the provided url: %s, err: %w", endpoint, err)
+	}
+
+	query := append([]byte(nil), msg...)
+	query[0], query[1] = 0, 0
+
+	v := u.Query()
+	v.Set("dns", base64.RawURLEncoding.EncodeToString(query))
+	u.RawQuery = v.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the HTTP request, err: %w", err)
+	}
+	req.Header.Set("accept", common.MediaTypeDNSMessage)
+
+	resp, err := common.ExchangeRaw(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 12 {
+		return nil, fmt.Errorf("the DNS response is too short: %d bytes", len(resp))
+	}
+	resp[0], resp[1] = msg[0], msg[1]
+	return resp, nil
+}
+
+// Pack builds the wire-format query message, padded according to the padding policy
+//
+// Arguments:
+//     None
+//
+// Returns:
+//     ([]byte): The packed DNS message
+//     (error):  An error if one exists, nil otherwise
+func (q QueryRequest) Pack() ([]byte, error) {
+	msg, _, err := q.pack()
+	return msg, err
+}
+
+// pack builds the query message and returns it along with the number of padding octets it holds
+func (q QueryRequest) pack() ([]byte, int, error) {
+	msg, err := q.build(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The padding option adds a 4 octet option header on top of the padding itself
+	n, err := common.PaddingLength(q.Padding, len(msg)+4)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q.Padding == "" || q.Padding == common.PaddingNone {
+		return msg, 0, nil
+	}
+
+	msg, err = q.build(&dnsmessage.Option{
+		Code: common.OptionPadding,
+		Data: make([]byte, n),
+	})
+	return msg, n, err
+}
+
+func (q QueryRequest) build(padding *dnsmessage.Option) ([]byte, error) {
+	name, err := common.ToASCII(q.Resource)
+	if err != nil {
+		return nil, err
+	}
+	name = common.FQDN(name)
+
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("error building the query name: %s, err: %w", name, err)
+	}
+
+	t, err := common.TypeFromName(q.ResourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 8484 recommends an ID of 0 so that GET requests are cache friendly
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		RecursionDesired: true,
+		CheckingDisabled: q.DisableDNSSECValidation,
+	})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{
+		Name:  n,
+		Type:  dnsmessage.Type(t),
+		Class: dnsmessage.ClassINET,
+	}); err != nil {
+		return nil, err
+	}
+
+	var opt dnsmessage.OPTResource
+	if q.EDNSClientSubnet != "" {
+		o, err := ClientSubnetOption(q.EDNSClientSubnet)
+		if err != nil {
+			return nil, err
+		}
+		opt.Options = append(opt.Options, o)
+	}
+	if padding != nil {
+		opt.Options = append(opt.Options, *padding)
+	}
+
+	var h dnsmessage.ResourceHeader
+	if err := h.SetEDNS0(4096, dnsmessage.RCodeSuccess, q.ShowDNSSEC); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(h, opt); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// ClientSubnetOption builds an RFC 7871 EDNS Client Subnet option
+//
+// Arguments:
+//     subnet (string): An address in CIDR notation, or a bare address which is truncated to a
+//                      /24 for IPv4 and a /56 for IPv6 as RFC 7871 recommends
+//
+// Returns:
+//     (dnsmessage.Option): The EDNS0 option
+//     (error):             An error if one exists, nil otherwise
+func ClientSubnetOption(subnet string) (dnsmessage.Option, error) {
+	var (
+		ip     net.IP
+		source int
+	)
+	if i := strings.IndexByte(subnet, '/'); i >= 0 {
+		ip = net.ParseIP(subnet[:i])
+		s, err := strconv.Atoi(subnet[i+1:])
+		if err != nil {
+			return dnsmessage.Option{}, fmt.Errorf("%s is not a valid client subnet", subnet)
+		}
+		source = s
+	} else {
+		ip = net.ParseIP(subnet)
+		source = 56
+		if ip.To4() != nil {
+			source = 24
+		}
+	}
+	if ip == nil {
+		return dnsmessage.Option{}, fmt.Errorf("%s is not a valid client subnet", subnet)
+	}
+
+	family, bits := 2, 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, family, bits = ip4, 1, 32
+	}
+	if source < 0 || source > bits {
+		return dnsmessage.Option{}, fmt.Errorf("%s is not a valid client subnet", subnet)
+	}
+
+	// The address is truncated to the source prefix length, with the host bits zeroed
+	addr := ip.Mask(net.CIDRMask(source, bits))[:(source+7)/8]
+	data := append([]byte{byte(family >> 8), byte(family), byte(source), 0}, addr...)
+	return dnsmessage.Option{
+		Code: common.OptionClientSubnet,
+		Data: data,
+	}, nil
+}
@@ -0,0 +1,162 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDo(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 15, dohtest.Answer{
+		AD:      true,
+		Records: []dohtest.Record{{Name: "example.com.", Type: 15, TTL: 300, Data: "10 mail.example.com."}},
+	})
+
+	resp, err := QueryRequest{
+		URL:              "https://doh.example.net/dns-query",
+		Resource:         "example.com",
+		ResourceType:     "MX",
+		EDNSClientSubnet: "198.51.100.7",
+		ShowDNSSEC:       true,
+	}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	req := s.LastRequest()
+	if req.Host != "doh.example.net" || req.URL.Path != "/dns-query" || req.Method != "GET" {
+		t.Errorf("request = %s %s%s, want GET doh.example.net/dns-query", req.Method, req.Host, req.URL.Path)
+	}
+	if got := req.Header.Get("Accept"); got != common.MediaTypeDNSMessage {
+		t.Errorf("Accept = %q, want %s", got, common.MediaTypeDNSMessage)
+	}
+
+	msg := req.Message
+	if msg.Header.ID != 0 || !msg.Header.RecursionDesired {
+		t.Errorf("header = %+v, want ID 0 with RD set", msg.Header)
+	}
+	if len(msg.Questions) != 1 || msg.Questions[0].Name.String() != "example.com." || msg.Questions[0].Type != dnsmessage.TypeMX {
+		t.Errorf("questions = %+v, want example.com. MX", msg.Questions)
+	}
+	opt := optResource(t, msg)
+	if !msg.Additionals[0].Header.DNSSECAllowed() {
+		t.Error("DO bit not set")
+	}
+	ecs := []byte{0, 1, 24, 0, 198, 51, 100}
+	if len(opt.Options) != 1 || opt.Options[0].Code != common.OptionClientSubnet || !bytes.Equal(opt.Options[0].Data, ecs) {
+		t.Errorf("options = %+v, want a single client subnet option of %v", opt.Options, ecs)
+	}
+
+	if !resp.AD {
+		t.Error("AD = false, want true")
+	}
+	if len(resp.Answer) != 1 {
+		t.Fatalf("answer = %+v, want a single record", resp.Answer)
+	}
+	if mx, ok := resp.Answer[0].RData.(common.MXData); !ok || mx.Preference != 10 || mx.Host != "mail.example.com." {
+		t.Errorf("rdata = %#v, want 10 mail.example.com.", resp.Answer[0].RData)
+	}
+}
+
+func TestDoBlockPadding(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+
+	resp, err := QueryRequest{
+		URL:          "https://doh.example.net/dns-query",
+		Resource:     "example.com",
+		ResourceType: "A",
+		Padding:      common.PaddingBlock,
+	}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if resp.Request.MessageSize%common.PaddingBlockSize != 0 {
+		t.Errorf("message size = %d, want a multiple of %d", resp.Request.MessageSize, common.PaddingBlockSize)
+	}
+	opt := optResource(t, s.LastRequest().Message)
+	if len(opt.Options) != 1 || opt.Options[0].Code != common.OptionPadding || len(opt.Options[0].Data) != resp.Request.Padding {
+		t.Errorf("options = %+v, want a single padding option of %d octets", opt.Options, resp.Request.Padding)
+	}
+}
+
+func TestDoNXDOMAIN(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("blocked.example", 1, dohtest.Answer{
+		Status:         3,
+		Authority:      []dohtest.Record{{Name: "example.", Type: 6, TTL: 60, Data: "ns.example. hostmaster.example. 2024010101 7200 3600 1209600 60"}},
+		ExtendedErrors: []common.ExtendedError{{InfoCode: 15, ExtraText: "policy"}},
+	})
+
+	resp, err := QueryRequest{URL: "https://doh.example.net/dns-query", Resource: "blocked.example", ResourceType: "A"}.Do()
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if resp.StatusCode != 3 || resp.StatusName != "NXDOMAIN" {
+		t.Errorf("status = %d %s, want 3 NXDOMAIN", resp.StatusCode, resp.StatusName)
+	}
+	if len(resp.Authority) != 1 {
+		t.Fatalf("authority = %+v, want a single SOA record", resp.Authority)
+	}
+	if soa, ok := resp.Authority[0].RData.(common.SOAData); !ok || soa.Serial != 2024010101 {
+		t.Errorf("authority rdata = %#v, want an SOA with serial 2024010101", resp.Authority[0].RData)
+	}
+	if len(resp.ExtendedErrors) != 1 || resp.ExtendedErrors[0].InfoCode != 15 || resp.ExtendedErrors[0].ExtraText != "policy" {
+		t.Errorf("extended errors = %+v, want a single EDE 15 with policy", resp.ExtendedErrors)
+	}
+}
+
+func TestForward(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{
+		Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "93.184.216.34"}},
+	})
+
+	msg, err := QueryRequest{Resource: "example.com", ResourceType: "A"}.Pack()
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+	msg[0], msg[1] = 0x12, 0x34
+
+	resp, err := Forward(context.Background(), "https://doh.example.net/dns-query", msg)
+	if err != nil {
+		t.Fatalf("Forward() error = %v", err)
+	}
+	if id := s.LastRequest().Message.Header.ID; id != 0 {
+		t.Errorf("sent ID = %d, want 0", id)
+	}
+
+	var m dnsmessage.Message
+	if err := m.Unpack(resp); err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	if m.Header.ID != 0x1234 {
+		t.Errorf("response ID = %#x, want 0x1234", m.Header.ID)
+	}
+	if len(m.Answers) != 1 || m.Answers[0].Header.Type != dnsmessage.TypeA {
+		t.Errorf("answers = %+v, want a single A record", m.Answers)
+	}
+}
+
+// optResource returns the OPT record of a query, failing the test when there is none
+func optResource(t *testing.T, msg *dnsmessage.Message) *dnsmessage.OPTResource {
+	t.Helper()
+	if msg == nil || len(msg.Additionals) != 1 {
+		t.Fatalf("additionals = %+v, want a single OPT record", msg)
+	}
+	opt, ok := msg.Additionals[0].Body.(*dnsmessage.OPTResource)
+	if !ok {
+		t.Fatalf("additional = %+v, want an OPT record", msg.Additionals[0])
+	}
+	return opt
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+	"github.com/spf13/cobra"
+)
+
+// propagationResult is what a single provider answered during a propagation check
+type propagationResult struct {
+	Provider string   `json:"provider"`
+	Location string   `json:"location,omitempty"`
+	Status   string   `json:"status,omitempty"`
+	Answers  []string `json:"answers,omitempty"`
+	Serial   *uint32  `json:"serial,omitempty"`
+	Seen     bool     `json:"seen"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// value is the string results are compared by, the SOA serial for SOA queries and the sorted
+// answers otherwise
+func (r propagationResult) value() string {
+	if r.Serial != nil {
+		return "serial " + strconv.FormatUint(uint64(*r.Serial), 10)
+	}
+	return strings.Join(r.Answers, ", ")
+}
+
+func newPropagationCmd() *cobra.Command {
+	var (
+		typeFlag   string
+		expectFlag string
+		formatFlag string
+		nextDNSID  string
+		wireFlag   bool
+		cmd        = &cobra.Command{
+			Use:     "propagation NAME",
+			Short:   "check which providers and locations see the current value of a record",
+			Example: "dohdig propagation example.com -t SOA",
+			Args:    cobra.ExactArgs(1),
+			Run: func(ccmd *cobra.Command, args []string) {
+				if formatFlag != "text" && formatFlag != "json" {
+					log.Fatalf("%s is an unsupported output format", formatFlag)
+				}
+
+				t, err := common.TypeFromName(typeFlag)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				results := propagation(provider.All(), args[0], typeFlag, t, nextDNSID, wireFlag)
+				expected := expectFlag
+				if t == 6 && expected != "" {
+					expected = "serial " + expected
+				}
+				if expected == "" {
+					expected = consensus(results)
+				}
+				markSeen(results, expected)
+
+				if formatFlag == "json" {
+					b, err := json.MarshalIndent(results, "", "  ")
+					if err != nil {
+						log.Fatalf("error marshalling results, err: %v", err)
+					}
+					fmt.Println(string(b))
+					return
+				}
+				printPropagation(args[0], typeFlag, expected, results)
+			},
+		}
+	)
+
+	cmd.Flags().StringVarP(&typeFlag, "record-type", "t", "A", "The DNS record type to query")
+	cmd.Flags().StringVar(&expectFlag, "expect", "", "The value, or SOA serial, to look for (default is the value most providers return)")
+	cmd.Flags().StringVarP(&formatFlag, "format", "f", "text", "The output format, one of: text, json")
+	cmd.Flags().StringVar(&nextDNSID, "nextdns-id", "", "The NextDNS profile ID, nextdns is skipped without one")
+	cmd.Flags().BoolVarP(&wireFlag, "wire", "w", false, "Send RFC 8484 wire-format queries instead of using the JSON API")
+	return cmd
+}
+
+// propagation queries name on every provider in parallel, skipping nextdns without a profile ID,
+// and returns the results in the order the providers were given
+func propagation(all []provider.Provider, name, typeName string, t int, nextDNSID string, wire bool) []propagationResult {
+	var providers []provider.Provider
+	for _, p := range all {
+		if p.Name == "nextdns" && nextDNSID == "" {
+			continue
+		}
+		providers = append(providers, p)
+	}
+
+	results := make([]propagationResult, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p provider.Provider) {
+			defer wg.Done()
+			r := propagationResult{Provider: p.Name, Location: p.Location}
+			resp, err := p.New(provider.Query{
+				Resource:     name,
+				ResourceType: typeName,
+				Padding:      common.PaddingBlock,
+				NextDNSID:    nextDNSID,
+				Wire:         wire,
+			}).Do()
+			if err != nil {
+				r.Error = err.Error()
+				results[i] = r
+				return
+			}
+
+			r.Status = resp.StatusName
+			for _, a := range resp.Answer {
+				if a.Type != t {
+					continue
+				}
+				if soa, ok := a.RData.(common.SOAData); ok {
+					serial := soa.Serial
+					r.Serial = &serial
+				}
+				r.Answers = append(r.Answers, a.Data)
+			}
+			sort.Strings(r.Answers)
+			results[i] = r
+		}(i, p)
+	}
+	wg.Wait()
+	return results
+}
+
+// consensus returns the value returned by the most providers, preferring the one seen first on a
+// tie so the result does not depend on map order
+func consensus(results []propagationResult) string {
+	var (
+		counts = make(map[string]int)
+		order  []string
+	)
+	for _, r := range results {
+		if r.Error != "" || len(r.Answers) == 0 {
+			continue
+		}
+		v := r.value()
+		if counts[v] == 0 {
+			order = append(order, v)
+		}
+		counts[v]++
+	}
+
+	var best string
+	for _, v := range order {
+		if counts[v] > counts[best] {
+			best = v
+		}
+	}
+	return best
+}
+
+// markSeen marks the results that returned the expected value, or for other than SOA queries
+// contain it among their answers. Nothing is seen when no value is expected, as no provider
+// answered
+func markSeen(results []propagationResult, expected string) {
+	for i, r := range results {
+		results[i].Seen = expected != "" && r.Error == "" && (r.value() == expected || (r.Serial == nil && containsFold(r.Answers, expected)))
+	}
+}
+
+func containsFold(answers []string, data string) bool {
+	for _, a := range answers {
+		if strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(data, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// printPropagation renders the results as a matrix of provider, location and answer
+func printPropagation(name, typeName, expected string, results []propagationResult) {
+	fmt.Printf("Propagation of %s %s\n", displayName(name), strings.ToUpper(typeName))
+	if expected == "" {
+		fmt.Println("No provider returned an answer")
+	} else {
+		fmt.Printf("Looking for: %s\n", expected)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tLOCATION\tSTATUS\tSEEN\tANSWER")
+	seen := 0
+	for _, r := range results {
+		status, mark, answer := r.Status, "no", r.value()
+		if r.Error != "" {
+			status, mark, answer = "ERROR", "-", r.Error
+		}
+		if r.Seen {
+			mark = "yes"
+			seen++
+		}
+		if answer == "" {
+			answer = "(none)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Provider, r.Location, status, mark, answer)
+	}
+	w.Flush()
+
+	if expected != "" {
+		fmt.Printf("\n%d/%d providers see %s\n", seen, len(results), expected)
+	}
+}
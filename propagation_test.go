@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+// propagationProviders registers a provider for each host and returns them, along with nextdns
+func propagationProviders(t *testing.T, hosts ...string) []provider.Provider {
+	t.Helper()
+	var providers []provider.Provider
+	for _, host := range append(hosts, "nextdns") {
+		if host != "nextdns" {
+			err := provider.RegisterCustom(provider.Custom{Name: host, Location: "Lab", URL: "https://" + host + "/resolve"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		p, err := provider.Get(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+func aRecord(data string) dohtest.Answer {
+	return dohtest.Answer{Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: data}}}
+}
+
+func TestPropagationConsensus(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+
+	for _, tt := range []struct {
+		name    string
+		answers []string
+		want    string
+		seen    []bool
+	}{
+		{"majority", []string{"192.0.2.1", "192.0.2.2", "192.0.2.2"}, "192.0.2.2", []bool{false, true, true}},
+		{"tie goes to the first seen", []string{"192.0.2.1", "192.0.2.2"}, "192.0.2.1", []bool{true, false}},
+		{"tie goes to the first seen, not the first counted", []string{"192.0.2.1", "192.0.2.2", "192.0.2.2", "192.0.2.1"}, "192.0.2.1", []bool{true, false, false, true}},
+		{"providers without answers are ignored", []string{"", "", "192.0.2.2"}, "192.0.2.2", []bool{false, false, true}},
+		{"no answers", []string{"", ""}, "", []bool{false, false}},
+	} {
+		var hosts []string
+		for i, data := range tt.answers {
+			host := "p" + string(rune('a'+i)) + ".propagation.test"
+			hosts = append(hosts, host)
+			a := dohtest.Answer{Status: 3}
+			if data != "" {
+				a = aRecord(data)
+			}
+			s.HandleHost(host, "example.com", 1, a)
+		}
+
+		results := propagation(propagationProviders(t, hosts...), "example.com", "A", 1, "", false)
+		if len(results) != len(tt.answers) {
+			t.Fatalf("%s: %d results, want %d", tt.name, len(results), len(tt.answers))
+		}
+		got := consensus(results)
+		if got != tt.want {
+			t.Errorf("%s: consensus() = %q, want %q", tt.name, got, tt.want)
+		}
+		markSeen(results, got)
+		for i, r := range results {
+			if r.Provider != hosts[i] || r.Seen != tt.seen[i] {
+				t.Errorf("%s: result %d = %s seen %v, want %s seen %v", tt.name, i, r.Provider, r.Seen, hosts[i], tt.seen[i])
+			}
+		}
+	}
+}
+
+func TestPropagationSOASerial(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+
+	// The serial is what counts, so SOA records that differ otherwise still agree
+	soa := func(mname string, serial string) dohtest.Answer {
+		return dohtest.Answer{Records: []dohtest.Record{{
+			Name: "example.com.",
+			Type: 6,
+			TTL:  3600,
+			Data: mname + " hostmaster.example.com. " + serial + " 7200 3600 1209600 300",
+		}}}
+	}
+	s.HandleHost("soa-a.propagation.test", "example.com", 6, soa("ns1.example.com.", "2021060100"))
+	s.HandleHost("soa-b.propagation.test", "example.com", 6, soa("ns1.example.com.", "2021060101"))
+	s.HandleHost("soa-c.propagation.test", "example.com", 6, soa("ns2.example.com.", "2021060101"))
+
+	results := propagation(
+		propagationProviders(t, "soa-a.propagation.test", "soa-b.propagation.test", "soa-c.propagation.test"),
+		"example.com", "SOA", 6, "", true)
+	for _, r := range results {
+		if r.Serial == nil {
+			t.Fatalf("%s: no serial in %+v", r.Provider, r)
+		}
+	}
+	if got := consensus(results); got != "serial 2021060101" {
+		t.Errorf("consensus() = %q, want serial 2021060101", got)
+	}
+
+	// An expected serial, as given with --expect, is compared the same way
+	markSeen(results, "serial 2021060100")
+	for i, want := range []bool{true, false, false} {
+		if results[i].Seen != want {
+			t.Errorf("%s: seen = %v, want %v", results[i].Provider, results[i].Seen, want)
+		}
+	}
+}
+
+func TestPropagationNextDNS(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, aRecord("192.0.2.1"))
+	providers := propagationProviders(t, "next-a.propagation.test")
+
+	results := propagation(providers, "example.com", "A", 1, "", false)
+	if len(results) != 1 || results[0].Provider != "next-a.propagation.test" {
+		t.Errorf("without a profile ID results = %+v, want only next-a.propagation.test", results)
+	}
+	for _, r := range s.Requests() {
+		if r.Host == "dns.nextdns.io" {
+			t.Errorf("nextdns was queried without a profile ID: %s", r.URL)
+		}
+	}
+
+	results = propagation(providers, "example.com", "A", 1, "abc123", false)
+	if len(results) != 2 || results[1].Provider != "nextdns" || results[1].Error != "" || results[1].value() != "192.0.2.1" {
+		t.Errorf("with a profile ID results = %+v, want nextdns answering 192.0.2.1", results)
+	}
+	queried := false
+	for _, r := range s.Requests() {
+		if r.Host == "dns.nextdns.io" && strings.HasPrefix(r.URL.Path, "/abc123") {
+			queried = true
+		}
+	}
+	if !queried {
+		t.Error("nextdns was not queried with the profile ID")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+	"github.com/j4ng5y/dohdig/pkg/report"
+	"github.com/spf13/cobra"
+)
+
+func newReportCmd() *cobra.Command {
+	var (
+		providerFlag string
+		formatFlag   string
+		nextDNSID    string
+		wireFlag     bool
+		cmd          = &cobra.Command{
+			Use:     "report DOMAIN",
+			Short:   "summarize the addresses, name servers, mail and policy records of a domain",
+			Example: "dohdig report example.com -i cloudflare",
+			Args:    cobra.ExactArgs(1),
+			Run: func(ccmd *cobra.Command, args []string) {
+				if formatFlag != "text" && formatFlag != "json" {
+					log.Fatalf("%s is an unsupported output format", formatFlag)
+				}
+				if providerFlag == "nextdns" && nextDNSID == "" {
+					log.Fatal("the --nextdns-id flag must be set to use NextDNS")
+				}
+
+				p, err := provider.Get(providerFlag)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				r := report.Run(p, provider.Query{
+					Padding:    common.PaddingBlock,
+					ShowDNSSEC: true,
+					NextDNSID:  nextDNSID,
+					Wire:       wireFlag,
+				}, args[0])
+
+				if formatFlag == "json" {
+					b, err := json.MarshalIndent(r, "", "  ")
+					if err != nil {
+						log.Fatalf("error marshalling the report, err: %v", err)
+					}
+					fmt.Println(string(b))
+					return
+				}
+				printReport(r)
+			},
+		}
+	)
+
+	cmd.Flags().StringVarP(&providerFlag, "provider", "i", "google", "The provider to use")
+	cmd.Flags().StringVarP(&formatFlag, "format", "f", "text", "The output format, one of: text, json")
+	cmd.Flags().StringVar(&nextDNSID, "nextdns-id", "", "The NextDNS profile ID, required by the nextdns provider")
+	cmd.Flags().BoolVarP(&wireFlag, "wire", "w", false, "Send RFC 8484 wire-format queries instead of using the JSON API")
+	return cmd
+}
+
+// printReport renders a report as a table of sections, with the MX targets under the MX records,
+// followed by the warnings
+func printReport(r *report.Report) {
+	fmt.Printf("Report for %s (provider: %s)\n\n", displayName(r.Domain), r.Provider)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range r.Sections {
+		lines := reportLines(s)
+		if s.Title == "MX" && len(r.MailExchangers) > 0 {
+			lines = nil
+			for _, m := range r.MailExchangers {
+				addrs := strings.Join(m.Addresses, ", ")
+				if addrs == "" {
+					addrs = "no addresses"
+				}
+				lines = append(lines, fmt.Sprintf("%d %s (%s)", m.Preference, m.Host, addrs))
+			}
+		}
+		for i, line := range lines {
+			title := s.Title
+			if i > 0 {
+				title = ""
+			}
+			fmt.Fprintf(w, "%s\t%s\n", title, line)
+		}
+	}
+	w.Flush()
+
+	fmt.Println()
+	if len(r.Warnings) == 0 {
+		fmt.Println("No warnings")
+		return
+	}
+	fmt.Println("Warnings:")
+	for _, warning := range r.Warnings {
+		fmt.Printf("  - %s\n", warning)
+	}
+}
+
+// reportLines returns the lines a section is shown as, one per record, with the aliases that led
+// to the records marked with their type
+func reportLines(s report.Section) []string {
+	if s.Error != "" {
+		return []string{"ERROR: " + s.Error}
+	}
+
+	var lines []string
+	for _, rec := range s.Records {
+		if rec.Type == s.Type {
+			lines = append(lines, rec.Data)
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", rec.Type, rec.Data))
+	}
+	if len(lines) == 0 {
+		if s.Status != "" && s.Status != "NOERROR" {
+			return []string{"(none, " + s.Status + ")"}
+		}
+		return []string{"(none)"}
+	}
+	return lines
+}
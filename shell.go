@@ -0,0 +1,401 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+)
+
+// shellCommands are the commands the shell understands, anything else is a name to query
+var shellCommands = []string{"set", "show", "help", "exit", "quit"}
+
+// shellSettings are the settings that can be changed with set, named after their flags
+var shellSettings = []string{
+	"provider",
+	"record-type",
+	"format",
+	"wire",
+	"show-dnssec",
+	"disable-dnssec-checking",
+	"edns-client-subnet",
+	"padding",
+	"verbose",
+	"tls-info",
+	"show-query",
+	"raw",
+	"nextdns-id",
+	"timeout",
+}
+
+// shellSession holds the settings that apply to every query run in the shell
+type shellSession struct {
+	provider  string
+	query     provider.Query
+	format    string
+	wire      bool
+	subnetSet bool
+	verbose   bool
+	tlsInfo   bool
+	showQuery bool
+	raw       bool
+}
+
+func newShellCmd() *cobra.Command {
+	var (
+		s = &shellSession{
+			query: provider.Query{
+				Padding:    common.PaddingBlock,
+				ShowDNSSEC: true,
+			},
+		}
+		cmd = &cobra.Command{
+			Use:   "shell",
+			Short: "run queries interactively, keeping settings between them",
+			Args:  cobra.NoArgs,
+			Run: func(ccmd *cobra.Command, args []string) {
+				if err := s.run(); err != nil {
+					log.Fatal(err)
+				}
+			},
+		}
+	)
+
+	cmd.Flags().StringVarP(&s.provider, "provider", "i", "google", "The provider to start with")
+	cmd.Flags().StringVarP(&s.query.ResourceType, "record-type", "t", "A", "The DNS record type to start with")
+	cmd.Flags().StringVarP(&s.format, "format", "f", "text", "The output format to start with, one of: text, json")
+	return cmd
+}
+
+// historyPath returns where the shell history is kept, or "" when there is no cache directory
+func historyPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dohdig", "history")
+}
+
+func (s *shellSession) run() error {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetCompleter(s.complete)
+
+	history := historyPath()
+	if history != "" {
+		if f, err := os.Open(history); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+
+	fmt.Println(`Type a name to query it, "help" for commands or "exit" to leave`)
+	for {
+		input, err := line.Prompt(fmt.Sprintf("dohdig (%s %s)> ", s.provider, strings.ToUpper(s.query.ResourceType)))
+		// Ctrl-C only drops the line being typed, the shell is left with exit or Ctrl-D
+		if err == liner.ErrPromptAborted {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading the input, err: %w", err)
+		}
+
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			continue
+		}
+		line.AppendHistory(input)
+
+		if fields[0] == "exit" || fields[0] == "quit" {
+			break
+		}
+		if err := s.exec(fields); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	if history != "" {
+		if err := os.MkdirAll(filepath.Dir(history), 0700); err != nil {
+			return fmt.Errorf("error creating the history directory, err: %w", err)
+		}
+		// The history holds every name looked up, so it is only readable by the user
+		f, err := os.OpenFile(history, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("error saving the history, err: %w", err)
+		}
+		defer f.Close()
+		if _, err := line.WriteHistory(f); err != nil {
+			return fmt.Errorf("error saving the history, err: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *shellSession) exec(fields []string) error {
+	switch fields[0] {
+	case "help":
+		fmt.Print(shellHelpStr)
+		fmt.Printf("Settings: %s\n", strings.Join(shellSettings, ", "))
+		return nil
+	case "show":
+		s.show()
+		return nil
+	case "set":
+		switch {
+		case len(fields) == 2 && isClearable(fields[1]):
+			return s.set(fields[1], "")
+		case len(fields) != 3:
+			return fmt.Errorf("usage: set SETTING VALUE, or set SETTING to clear edns-client-subnet and nextdns-id")
+		}
+		return s.set(fields[1], fields[2])
+	default:
+		if len(fields) > 2 {
+			return fmt.Errorf("usage: NAME [TYPE]")
+		}
+		t := s.query.ResourceType
+		if len(fields) == 2 {
+			t = fields[1]
+		}
+		return s.lookup(fields[0], t)
+	}
+}
+
+// lookup queries a single name with the session's settings
+func (s *shellSession) lookup(name, t string) error {
+	if _, err := common.TypeFromName(t); err != nil {
+		return err
+	}
+
+	p, err := provider.Get(s.provider)
+	if err != nil {
+		return err
+	}
+	if p.Name == "nextdns" && s.query.NextDNSID == "" {
+		return fmt.Errorf("set nextdns-id to use NextDNS")
+	}
+
+	q := s.query
+	q.Resource = name
+	q.ResourceType = t
+	q.Wire = s.wire || (s.subnetSet && !p.JSONClientSubnet)
+
+	if s.format == "text" {
+		fmt.Printf("Querying: %s\n", displayName(name))
+	}
+	resp, err := p.New(q).Do()
+	if err != nil {
+		if s.raw {
+			printFailedResponse(err)
+		}
+		return err
+	}
+	return printResponse(resp, printOptions{
+		format:    s.format,
+		verbose:   s.verbose,
+		tlsInfo:   s.tlsInfo,
+		showQuery: s.showQuery,
+		raw:       s.raw,
+	})
+}
+
+func (s *shellSession) set(key, value string) error {
+	var err error
+	switch key {
+	case "provider":
+		if _, err := provider.Get(value); err != nil {
+			return err
+		}
+		s.provider = value
+	case "record-type":
+		if _, err := common.TypeFromName(value); err != nil {
+			return err
+		}
+		s.query.ResourceType = value
+	case "format":
+		if value != "text" && value != "json" {
+			return fmt.Errorf("%s is an unsupported output format", value)
+		}
+		s.format = value
+	case "wire":
+		s.wire, err = parseSwitch(value)
+	case "show-dnssec":
+		s.query.ShowDNSSEC, err = parseSwitch(value)
+	case "disable-dnssec-checking":
+		s.query.DisableDNSSECValidation, err = parseSwitch(value)
+	case "edns-client-subnet":
+		s.query.EDNSClientSubnet = value
+		s.subnetSet = value != "" && value != "0.0.0.0/0"
+	case "padding":
+		if _, err := common.PaddingLength(value, 0); err != nil {
+			return err
+		}
+		s.query.Padding = value
+	case "verbose":
+		s.verbose, err = parseSwitch(value)
+	case "tls-info":
+		s.tlsInfo, err = parseSwitch(value)
+	case "show-query":
+		s.showQuery, err = parseSwitch(value)
+	case "raw":
+		s.raw, err = parseSwitch(value)
+	case "nextdns-id":
+		s.query.NextDNSID = value
+	case "timeout":
+		var d time.Duration
+		d, err = time.ParseDuration(value)
+		if err == nil {
+			common.Client.Timeout = d
+		}
+	default:
+		return fmt.Errorf("%s is not a setting, expected one of: %s", key, strings.Join(shellSettings, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("%s is not a valid value for %s", value, key)
+	}
+	return nil
+}
+
+func (s *shellSession) show() {
+	fmt.Printf(
+		shellShowStr,
+		s.provider,
+		strings.ToUpper(s.query.ResourceType),
+		s.format,
+		s.wire,
+		s.query.ShowDNSSEC,
+		s.query.DisableDNSSECValidation,
+		s.query.EDNSClientSubnet,
+		s.query.Padding,
+		s.verbose,
+		s.tlsInfo,
+		s.showQuery,
+		s.raw,
+		s.query.NextDNSID,
+		common.Client.Timeout)
+}
+
+// complete returns the completions for the line typed so far: commands first, then setting
+// names, providers and record types depending on what precedes the word being typed
+func (s *shellSession) complete(line string) []string {
+	fields := strings.Fields(line)
+	var word string
+	if len(fields) > 0 && !strings.HasSuffix(line, " ") {
+		// Complete the last word rather than starting a new one
+		word = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+	prefix := strings.Join(fields, " ")
+	if prefix != "" {
+		prefix += " "
+	}
+
+	var candidates []string
+	switch {
+	case len(fields) == 0:
+		candidates = shellCommands
+	case len(fields) == 1 && fields[0] == "set":
+		candidates = shellSettings
+	case len(fields) == 2 && fields[0] == "set" && fields[1] == "provider":
+		candidates = provider.Names()
+	case len(fields) == 2 && fields[0] == "set" && fields[1] == "record-type":
+		candidates = common.TypeNames()
+	case len(fields) == 2 && fields[0] == "set" && fields[1] == "format":
+		candidates = []string{"text", "json"}
+	case len(fields) == 2 && fields[0] == "set" && fields[1] == "padding":
+		candidates = []string{common.PaddingNone, common.PaddingBlock, common.PaddingRandom}
+	case len(fields) == 2 && fields[0] == "set" && isSwitch(fields[1]):
+		candidates = []string{"on", "off"}
+	case len(fields) == 1 && !isShellCommand(fields[0]):
+		candidates = common.TypeNames()
+	}
+
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToLower(c), strings.ToLower(word)) {
+			out = append(out, prefix+c)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func isShellCommand(word string) bool {
+	for _, c := range shellCommands {
+		if c == word {
+			return true
+		}
+	}
+	return false
+}
+
+// isSwitch reports whether a setting is turned on and off rather than taking a value
+func isSwitch(setting string) bool {
+	switch setting {
+	case "wire", "show-dnssec", "disable-dnssec-checking", "verbose", "tls-info", "show-query", "raw":
+		return true
+	default:
+		return false
+	}
+}
+
+// isClearable reports whether a setting can be cleared by setting it without a value
+func isClearable(setting string) bool {
+	switch setting {
+	case "edns-client-subnet", "nextdns-id":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseSwitch parses a boolean setting, which may also be given as on or off
+func parseSwitch(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	default:
+		return strconv.ParseBool(value)
+	}
+}
+
+const shellHelpStr string = `Commands:
+  NAME [TYPE]          Query NAME, with TYPE instead of the current record type
+  set SETTING VALUE    Change a setting for the rest of the session
+  set SETTING          Clear edns-client-subnet or nextdns-id
+  show                 Show the current settings
+  help                 Show this help
+  exit, quit           Leave the shell
+`
+
+const shellShowStr string = `Settings:
+Provider:           %s
+Record Type:        %s
+Format:             %s
+Wire:               %v
+Show DNSSEC:        %v
+Disable DNSSEC:     %v
+eDNS Client Subnet: %s
+Padding Policy:     %s
+Verbose:            %v
+TLS Info:           %v
+Show Query:         %v
+Raw:                %v
+NextDNS ID:         %s
+Timeout:            %s
+`
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+func newTestSession() *shellSession {
+	return &shellSession{
+		provider: "google",
+		format:   "text",
+		query:    provider.Query{ResourceType: "A", Padding: common.PaddingBlock, ShowDNSSEC: true},
+	}
+}
+
+func TestShellSet(t *testing.T) {
+	defer func(d time.Duration) { common.Client.Timeout = d }(common.Client.Timeout)
+
+	for _, tt := range []struct {
+		key, value string
+		want       func(s *shellSession) bool
+	}{
+		{"provider", "cloudflare", func(s *shellSession) bool { return s.provider == "cloudflare" }},
+		{"record-type", "mx", func(s *shellSession) bool { return s.query.ResourceType == "mx" }},
+		{"format", "json", func(s *shellSession) bool { return s.format == "json" }},
+		{"wire", "on", func(s *shellSession) bool { return s.wire }},
+		{"show-dnssec", "off", func(s *shellSession) bool { return !s.query.ShowDNSSEC }},
+		{"disable-dnssec-checking", "true", func(s *shellSession) bool { return s.query.DisableDNSSECValidation }},
+		{"verbose", "1", func(s *shellSession) bool { return s.verbose }},
+		{"tls-info", "ON", func(s *shellSession) bool { return s.tlsInfo }},
+		{"show-query", "on", func(s *shellSession) bool { return s.showQuery }},
+		{"raw", "on", func(s *shellSession) bool { return s.raw }},
+		{"padding", "random", func(s *shellSession) bool { return s.query.Padding == common.PaddingRandom }},
+		{"edns-client-subnet", "192.0.2.0/24", func(s *shellSession) bool { return s.subnetSet && s.query.EDNSClientSubnet == "192.0.2.0/24" }},
+		{"edns-client-subnet", "0.0.0.0/0", func(s *shellSession) bool { return !s.subnetSet }},
+		{"nextdns-id", "abc123", func(s *shellSession) bool { return s.query.NextDNSID == "abc123" }},
+		{"timeout", "5s", func(s *shellSession) bool { return common.Client.Timeout == 5*time.Second }},
+	} {
+		s := newTestSession()
+		if err := s.set(tt.key, tt.value); err != nil {
+			t.Errorf("set(%s, %s) error = %v", tt.key, tt.value, err)
+			continue
+		}
+		if !tt.want(s) {
+			t.Errorf("set(%s, %s) left the session as %+v", tt.key, tt.value, s)
+		}
+	}
+
+	for _, tt := range []struct {
+		key, value, want string
+	}{
+		{"provider", "bogus", "bogus"},
+		{"record-type", "BOGUS", "BOGUS"},
+		{"format", "xml", "xml is an unsupported output format"},
+		{"wire", "sometimes", "sometimes is not a valid value for wire"},
+		{"raw", "", " is not a valid value for raw"},
+		{"padding", "sometimes", "sometimes"},
+		{"timeout", "soon", "soon is not a valid value for timeout"},
+		{"colour", "on", "colour is not a setting"},
+	} {
+		s := newTestSession()
+		err := s.set(tt.key, tt.value)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("set(%s, %s) error = %v, want one containing %q", tt.key, tt.value, err, tt.want)
+		}
+		if !reflect.DeepEqual(s, newTestSession()) {
+			t.Errorf("set(%s, %s) changed the session to %+v", tt.key, tt.value, s)
+		}
+	}
+}
+
+func TestShellSetClear(t *testing.T) {
+	s := newTestSession()
+	for _, fields := range [][]string{
+		{"set", "edns-client-subnet", "192.0.2.0/24"},
+		{"set", "nextdns-id", "abc123"},
+		{"set", "edns-client-subnet"},
+		{"set", "nextdns-id"},
+	} {
+		if err := s.exec(fields); err != nil {
+			t.Fatalf("exec(%v) error = %v", fields, err)
+		}
+	}
+	if s.query.EDNSClientSubnet != "" || s.subnetSet || s.query.NextDNSID != "" {
+		t.Errorf("session after clearing = %+v, want no subnet or profile ID", s)
+	}
+
+	for _, fields := range [][]string{{"set", "provider"}, {"set"}, {"set", "wire", "on", "off"}} {
+		if err := s.exec(fields); err == nil || !strings.HasPrefix(err.Error(), "usage: set") {
+			t.Errorf("exec(%v) error = %v, want the usage", fields, err)
+		}
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	var providers []string
+	for _, name := range provider.Names() {
+		providers = append(providers, "set provider "+name)
+	}
+	sort.Strings(providers)
+
+	s := newTestSession()
+	for _, tt := range []struct {
+		line string
+		want []string
+	}{
+		{"", []string{"exit", "help", "quit", "set", "show"}},
+		{"s", []string{"set", "show"}},
+		{"SH", []string{"show"}},
+		{"set t", []string{"set timeout", "set tls-info"}},
+		{"set show", []string{"set show-dnssec", "set show-query"}},
+		{"set provider cloud", []string{"set provider cloudflare"}},
+		{"set provider ", providers},
+		{"set record-type aaa", []string{"set record-type AAAA"}},
+		{"set format ", []string{"set format json", "set format text"}},
+		{"set padding b", []string{"set padding block"}},
+		{"set wire ", []string{"set wire off", "set wire on"}},
+		{"set raw of", []string{"set raw off"}},
+		{"example.com aaa", []string{"example.com AAAA"}},
+		{"set timeout ", nil},
+		{"set nextdns-id ", nil},
+		{"show ", nil},
+		{"example.com AAAA ", nil},
+	} {
+		if got := s.complete(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("complete(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseSwitch(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  bool
+	}{
+		{"on", true},
+		{"ON", true},
+		{"off", false},
+		{"true", true},
+		{"0", false},
+	} {
+		got, err := parseSwitch(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSwitch(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"", "yes", "maybe"} {
+		if _, err := parseSwitch(value); err == nil {
+			t.Errorf("parseSwitch(%q) error = nil", value)
+		}
+	}
+
+	for _, tt := range []struct {
+		setting string
+		want    bool
+	}{
+		{"wire", true},
+		{"show-dnssec", true},
+		{"raw", true},
+		{"provider", false},
+		{"nextdns-id", false},
+		{"timeout", false},
+	} {
+		if got := isSwitch(tt.setting); got != tt.want {
+			t.Errorf("isSwitch(%s) = %v, want %v", tt.setting, got, tt.want)
+		}
+	}
+}
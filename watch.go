@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// watch re-runs the query every interval, printing the answer with the records that were added,
+// removed or changed since the previous response highlighted, until it is interrupted or, when
+// until is set, an answer with that data appears
+func watch(req common.Do, interval time.Duration, until, format string) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previous []common.QueryResponseAnswer
+	first := true
+	for {
+		resp, err := req.Do()
+		now := time.Now()
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "[%s] %v\n", now.Format("15:04:05"), err)
+		case format == "json":
+			resp.Request, resp.Response, resp.TLS = nil, nil, nil
+			if err := resp.PrintJSON(); err != nil {
+				return err
+			}
+		default:
+			printWatch(now, resp, previous, first)
+		}
+
+		if err == nil {
+			previous, first = resp.Answer, false
+			if until != "" && resp.HasAnswer(until) {
+				if format != "json" {
+					fmt.Printf("%s appeared, stopping\n", until)
+				}
+				return nil
+			}
+		}
+
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// printWatch prints a single watch iteration, marking records with + when added, - when removed
+// and ~ when another record of the same name and type was replaced
+func printWatch(now time.Time, resp *common.QueryResponse, previous []common.QueryResponseAnswer, first bool) {
+	current := make(map[string]bool)
+	for _, a := range resp.Answer {
+		current[recordKey(a)] = true
+	}
+	before := make(map[string]bool)
+	for _, a := range previous {
+		before[recordKey(a)] = true
+	}
+
+	// An RRset counts as changed when it both gained and lost records
+	removed := make(map[string]bool)
+	var gone []common.QueryResponseAnswer
+	for _, a := range previous {
+		if !current[recordKey(a)] {
+			removed[rrsetKey(a)] = true
+			gone = append(gone, a)
+		}
+	}
+
+	fmt.Printf("[%s] %s, %d answers\n", now.Format("15:04:05"), resp.StatusName, len(resp.Answer))
+	color := isTerminal()
+	for _, a := range resp.Answer {
+		marker, c := " ", ""
+		if !first && !before[recordKey(a)] {
+			marker, c = "+", colorGreen
+			if removed[rrsetKey(a)] {
+				marker, c = "~", colorYellow
+			}
+		}
+		a.DetermineTypeNameAndMeaning()
+		expires := now.Add(time.Duration(a.TTL) * time.Second)
+		printWatchLine(color, c, fmt.Sprintf(
+			"  %s %s\t%d\t%s\t%s\t(expires in %s at %s)",
+			marker,
+			a.Name,
+			a.TTL,
+			a.TypeName,
+			a.Data,
+			time.Duration(a.TTL)*time.Second,
+			expires.Format("15:04:05")))
+	}
+
+	sort.Slice(gone, func(i, j int) bool { return recordKey(gone[i]) < recordKey(gone[j]) })
+	for _, a := range gone {
+		a.DetermineTypeNameAndMeaning()
+		printWatchLine(color, colorRed, fmt.Sprintf("  - %s\t\t%s\t%s", a.Name, a.TypeName, a.Data))
+	}
+}
+
+func printWatchLine(color bool, c, line string) {
+	if !color || c == "" {
+		fmt.Println(line)
+		return
+	}
+	fmt.Println(c + line + colorReset)
+}
+
+func recordKey(a common.QueryResponseAnswer) string {
+	return rrsetKey(a) + " " + a.Data
+}
+
+func rrsetKey(a common.QueryResponseAnswer) string {
+	return fmt.Sprintf("%s %d", strings.ToLower(a.Name), a.Type)
+}
+
+// isTerminal reports whether stdout is a terminal, so that colors are only used interactively
+func isTerminal() bool {
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
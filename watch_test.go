@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j4ng5y/dohdig/pkg/common"
+	"github.com/j4ng5y/dohdig/pkg/dohtest"
+	"github.com/j4ng5y/dohdig/pkg/provider"
+)
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		var b bytes.Buffer
+		io.Copy(&b, r)
+		out <- b.String()
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func rr(name string, t, ttl int, data string) common.QueryResponseAnswer {
+	return common.QueryResponseAnswer{Name: name, Type: t, TTL: ttl, Data: data}
+}
+
+func TestPrintWatch(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	a1, a2 := rr("example.com.", 1, 300, "192.0.2.1"), rr("example.com.", 1, 60, "192.0.2.2")
+	mx := rr("example.com.", 15, 3600, "10 mail.example.com.")
+
+	for _, tt := range []struct {
+		name     string
+		answer   []common.QueryResponseAnswer
+		previous []common.QueryResponseAnswer
+		first    bool
+		want     []string
+	}{
+		{
+			name:   "first response has no markers",
+			answer: []common.QueryResponseAnswer{a1},
+			first:  true,
+			want: []string{
+				"[12:00:00] NOERROR, 1 answers",
+				"    example.com.\t300\tA\t192.0.2.1\t(expires in 5m0s at 12:05:00)",
+			},
+		},
+		{
+			name:     "unchanged",
+			answer:   []common.QueryResponseAnswer{rr("EXAMPLE.com.", 1, 240, "192.0.2.1")},
+			previous: []common.QueryResponseAnswer{a1},
+			want: []string{
+				"[12:00:00] NOERROR, 1 answers",
+				"    EXAMPLE.com.\t240\tA\t192.0.2.1\t(expires in 4m0s at 12:04:00)",
+			},
+		},
+		{
+			name:     "added to another RRset",
+			answer:   []common.QueryResponseAnswer{a1, mx},
+			previous: []common.QueryResponseAnswer{a1},
+			want: []string{
+				"[12:00:00] NOERROR, 2 answers",
+				"    example.com.\t300\tA\t192.0.2.1\t(expires in 5m0s at 12:05:00)",
+				"  + example.com.\t3600\tMX\t10 mail.example.com.\t(expires in 1h0m0s at 13:00:00)",
+			},
+		},
+		{
+			name:     "replaced in the same RRset",
+			answer:   []common.QueryResponseAnswer{a2},
+			previous: []common.QueryResponseAnswer{a1},
+			want: []string{
+				"[12:00:00] NOERROR, 1 answers",
+				"  ~ example.com.\t60\tA\t192.0.2.2\t(expires in 1m0s at 12:01:00)",
+				"  - example.com.\t\tA\t192.0.2.1",
+			},
+		},
+		{
+			name:     "removed",
+			answer:   nil,
+			previous: []common.QueryResponseAnswer{mx, a2, a1},
+			want: []string{
+				"[12:00:00] NOERROR, 0 answers",
+				"  - example.com.\t\tA\t192.0.2.1",
+				"  - example.com.\t\tA\t192.0.2.2",
+				"  - example.com.\t\tMX\t10 mail.example.com.",
+			},
+		},
+	} {
+		resp := &common.QueryResponse{StatusName: "NOERROR", Answer: tt.answer}
+		got := captureStdout(t, func() { printWatch(now, resp, tt.previous, tt.first) })
+		if want := strings.Join(tt.want, "\n") + "\n"; got != want {
+			t.Errorf("%s: printWatch() =\n%s\nwant\n%s", tt.name, got, want)
+		}
+	}
+}
+
+func TestRecordKey(t *testing.T) {
+	for _, tt := range []struct {
+		a, b common.QueryResponseAnswer
+		same bool
+	}{
+		{rr("example.com.", 1, 300, "192.0.2.1"), rr("Example.COM.", 1, 60, "192.0.2.1"), true},
+		{rr("example.com.", 1, 300, "192.0.2.1"), rr("example.com.", 1, 300, "192.0.2.2"), false},
+		{rr("example.com.", 1, 300, "192.0.2.1"), rr("www.example.com.", 1, 300, "192.0.2.1"), false},
+		{rr("example.com.", 16, 300, "v=spf1 -all"), rr("example.com.", 99, 300, "v=spf1 -all"), false},
+	} {
+		if got := recordKey(tt.a) == recordKey(tt.b); got != tt.same {
+			t.Errorf("recordKey(%+v) == recordKey(%+v) is %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
+
+func TestWatchUntil(t *testing.T) {
+	s := dohtest.NewServer()
+	defer s.Close()
+	s.Handle("example.com", 1, dohtest.Answer{Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 300, Data: "192.0.2.1"}}})
+
+	p, err := provider.Get("cloudflare")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The answer changes once the first query was answered, and watch stops when it appears
+	go func() {
+		for len(s.Requests()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		s.Handle("example.com", 1, dohtest.Answer{Records: []dohtest.Record{{Name: "example.com.", Type: 1, TTL: 60, Data: "192.0.2.2"}}})
+	}()
+
+	errc := make(chan error, 1)
+	out := captureStdout(t, func() {
+		errc <- watch(p.New(provider.Query{Resource: "example.com", ResourceType: "A"}), 10*time.Millisecond, "192.0.2.2", "text")
+	})
+	if err := <-errc; err != nil {
+		t.Fatalf("watch() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"    example.com.\t300\tA\t192.0.2.1\t",
+		"  ~ example.com.\t60\tA\t192.0.2.2\t",
+		"  - example.com.\t\tA\t192.0.2.1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("watch() output does not contain %q:\n%s", want, out)
+		}
+	}
+	if !strings.HasSuffix(out, "192.0.2.2 appeared, stopping\n") {
+		t.Errorf("watch() output does not end with the --until message:\n%s", out)
+	}
+	if n := strings.Count(out, "192.0.2.2\t("); n != 1 {
+		t.Errorf("watch() printed the awaited answer %d times, want it to stop after the first", n)
+	}
+}